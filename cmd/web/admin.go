@@ -0,0 +1,404 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/lobre/doodle/pkg/forms"
+	"github.com/lobre/doodle/pkg/models"
+)
+
+// adminPageSize is the number of users or events shown per page of the
+// administration area, and the number of entries of the audit trail.
+const adminPageSize = 50
+
+func (app *application) adminHome(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// adminUsers lists the users whose name or email contains the query,
+// if any, by order of registration.
+func (app *application) adminUsers(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(r.URL.Query())
+	form.MaxLength("q", 255)
+
+	f := models.UserFilter{Query: form.Get("q"), Limit: adminPageSize}
+
+	if after := form.Get("after"); after != "" {
+		id, err := strconv.Atoi(after)
+		if err != nil || id < 1 {
+			app.notFound(w)
+			return
+		}
+		f.After = id
+	}
+
+	if !form.Valid() {
+		app.render(w, r, "admin_users.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	page, err := app.userStore.List(r.Context(), f)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	td := &templateData{Form: form, Users: page.Users}
+
+	if page.Next != 0 {
+		query := r.URL.Query()
+		query.Set("after", strconv.Itoa(page.Next))
+		td.NextPage = "/admin/users?" + query.Encode()
+	}
+
+	app.render(w, r, "admin_users.page.tmpl", td)
+}
+
+// adminEvents lists the upcoming or past events whose title contains the
+// query, if any, including the hidden ones.
+func (app *application) adminEvents(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(r.URL.Query())
+	form.MaxLength("q", 100)
+	form.PermittedValues("when", "upcoming", "past")
+
+	past := form.Get("when") == "past"
+
+	// past events are listed as in the archive, the latest first
+	f := models.EventFilter{
+		Title:  form.Get("q"),
+		Past:   past,
+		Desc:   past,
+		Hidden: true,
+		Limit:  adminPageSize,
+	}
+
+	if after := form.Get("after"); after != "" {
+		cursor, err := models.ParseCursor(after)
+		if err != nil {
+			app.notFound(w)
+			return
+		}
+		f.After = cursor
+	}
+
+	if !form.Valid() {
+		app.render(w, r, "admin_events.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	page, err := app.eventStore.List(r.Context(), f)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	td := &templateData{Form: form, Events: page.Events}
+
+	if page.Next != nil {
+		query := r.URL.Query()
+		query.Set("after", page.Next.String())
+		td.NextPage = "/admin/events?" + query.Encode()
+	}
+
+	app.render(w, r, "admin_events.page.tmpl", td)
+}
+
+// adminAudit shows the audit trail, the latest actions first.
+func (app *application) adminAudit(w http.ResponseWriter, r *http.Request) {
+	before := 0
+	if b := r.URL.Query().Get("before"); b != "" {
+		var err error
+		before, err = strconv.Atoi(b)
+		if err != nil || before < 1 {
+			app.notFound(w)
+			return
+		}
+	}
+
+	page, err := app.auditStore.List(r.Context(), before, adminPageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	td := &templateData{AuditEntries: page.Entries}
+
+	if page.Next != 0 {
+		td.NextPage = fmt.Sprintf("/admin/audit?before=%d", page.Next)
+	}
+	if before > 0 {
+		td.PrevPage = "/admin/audit"
+	}
+
+	app.render(w, r, "audit.page.tmpl", td)
+}
+
+func (app *application) adminActivateUser(w http.ResponseWriter, r *http.Request) {
+	user := app.adminUser(w, r)
+	if user == nil {
+		return
+	}
+
+	err := app.userStore.Activate(r.Context(), user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if !app.audit(w, r, models.ActionActivateUser, user.ID) {
+		return
+	}
+
+	app.session.Put(r, "flash", fmt.Sprintf("The account of %s has been activated.", user.Name))
+	http.Redirect(w, r, adminUserURL(user), http.StatusSeeOther)
+}
+
+// adminDeactivateUser disables the account of a user, who is logged out
+// on their next request. The last active administrator cannot be
+// deactivated, so that there is always one left.
+func (app *application) adminDeactivateUser(w http.ResponseWriter, r *http.Request) {
+	user := app.adminUser(w, r)
+	if user == nil {
+		return
+	}
+
+	err := app.userStore.Deactivate(r.Context(), user.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrLastAdmin) {
+			app.lastAdmin(w, r, user)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	if !app.audit(w, r, models.ActionDeactivateUser, user.ID) {
+		return
+	}
+
+	app.session.Put(r, "flash", fmt.Sprintf("The account of %s has been deactivated.", user.Name))
+	http.Redirect(w, r, adminUserURL(user), http.StatusSeeOther)
+}
+
+func (app *application) adminPromoteUser(w http.ResponseWriter, r *http.Request) {
+	user := app.adminUser(w, r)
+	if user == nil {
+		return
+	}
+
+	err := app.userStore.SetRole(r.Context(), user.ID, models.RoleAdmin)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if !app.audit(w, r, models.ActionPromoteUser, user.ID) {
+		return
+	}
+
+	app.session.Put(r, "flash", fmt.Sprintf("%s is now an administrator.", user.Name))
+	http.Redirect(w, r, adminUserURL(user), http.StatusSeeOther)
+}
+
+// adminDemoteUser turns an administrator back into a regular user. The last
+// active administrator cannot be demoted, so that there is always one left.
+func (app *application) adminDemoteUser(w http.ResponseWriter, r *http.Request) {
+	user := app.adminUser(w, r)
+	if user == nil {
+		return
+	}
+
+	err := app.userStore.SetRole(r.Context(), user.ID, models.RoleUser)
+	if err != nil {
+		if errors.Is(err, models.ErrLastAdmin) {
+			app.lastAdmin(w, r, user)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	if !app.audit(w, r, models.ActionDemoteUser, user.ID) {
+		return
+	}
+
+	app.session.Put(r, "flash", fmt.Sprintf("%s is no longer an administrator.", user.Name))
+	http.Redirect(w, r, adminUserURL(user), http.StatusSeeOther)
+}
+
+// adminResetUser emails a link to the user to choose a new password. The
+// current password keeps working until then, and administrators never get
+// to know the new one.
+func (app *application) adminResetUser(w http.ResponseWriter, r *http.Request) {
+	user := app.adminUser(w, r)
+	if user == nil {
+		return
+	}
+
+	if !user.Active {
+		app.session.Put(r, "flash", "Inactive users cannot reset their password. Activate the account first.")
+		http.Redirect(w, r, adminUserURL(user), http.StatusSeeOther)
+		return
+	}
+
+	err := app.sendPasswordReset(r, user.Email, "An administrator asked you to choose a new password for your Doodle account.")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if !app.audit(w, r, models.ActionResetUser, user.ID) {
+		return
+	}
+
+	app.session.Put(r, "flash", fmt.Sprintf("A link to reset their password has been sent to %s.", user.Email))
+	http.Redirect(w, r, adminUserURL(user), http.StatusSeeOther)
+}
+
+// adminHideEvent hides an event from everyone but administrators,
+// including its owner, until it is shown again.
+func (app *application) adminHideEvent(w http.ResponseWriter, r *http.Request) {
+	evt := app.event(w, r)
+	if evt == nil {
+		return
+	}
+
+	err := app.eventStore.SetHidden(r.Context(), evt.ID, true)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if !app.audit(w, r, models.ActionHideEvent, evt.ID) {
+		return
+	}
+
+	app.session.Put(r, "flash", fmt.Sprintf("The event %q has been hidden.", evt.Title))
+	http.Redirect(w, r, adminEventURL(evt), http.StatusSeeOther)
+}
+
+func (app *application) adminShowEvent(w http.ResponseWriter, r *http.Request) {
+	evt := app.event(w, r)
+	if evt == nil {
+		return
+	}
+
+	err := app.eventStore.SetHidden(r.Context(), evt.ID, false)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if !app.audit(w, r, models.ActionShowEvent, evt.ID) {
+		return
+	}
+
+	app.session.Put(r, "flash", fmt.Sprintf("The event %q is visible again.", evt.Title))
+	http.Redirect(w, r, adminEventURL(evt), http.StatusSeeOther)
+}
+
+func (app *application) adminDeleteEvent(w http.ResponseWriter, r *http.Request) {
+	evt := app.event(w, r)
+	if evt == nil {
+		return
+	}
+
+	err := app.eventStore.Delete(r.Context(), evt.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if !app.audit(w, r, models.ActionDeleteEvent, evt.ID) {
+		return
+	}
+
+	app.session.Put(r, "flash", fmt.Sprintf("The event %q has been deleted.", evt.Title))
+	http.Redirect(w, r, "/admin/events", http.StatusSeeOther)
+}
+
+// The adminUser helper fetches the user whose ID is given in the URL. If it
+// cannot be retrieved, the appropriate error is sent and nil is returned.
+func (app *application) adminUser(w http.ResponseWriter, r *http.Request) *models.User {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil
+	}
+
+	user, err := app.userStore.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil
+	}
+
+	return user
+}
+
+// The lastAdmin helper sends the administrator back with a message when the
+// user is the last active administrator.
+func (app *application) lastAdmin(w http.ResponseWriter, r *http.Request, user *models.User) {
+	app.session.Put(r, "flash", fmt.Sprintf("%s is the last active administrator.", user.Name))
+	http.Redirect(w, r, adminUserURL(user), http.StatusSeeOther)
+}
+
+// adminUserURL returns the page of the administration area listing the user.
+func adminUserURL(user *models.User) string {
+	return "/admin/users?q=" + url.QueryEscape(user.Email)
+}
+
+// adminEventURL returns the page of the administration area listing the event.
+func adminEventURL(evt *models.Event) string {
+	query := url.Values{}
+	query.Set("q", evt.Title)
+	if evt.Past() {
+		query.Set("when", "past")
+	}
+	return "/admin/events?" + query.Encode()
+}
+
+// runPromote runs the promote subcommand given in args, which makes a user
+// an administrator. It is how the first one is appointed.
+func runPromote(users models.UserStore, audit models.AuditStore, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: promote EMAIL")
+	}
+
+	ctx := context.Background()
+
+	user, err := users.GetByEmail(ctx, args[0])
+	if errors.Is(err, models.ErrNoRecord) {
+		return fmt.Errorf("no user has the email %q", args[0])
+	} else if err != nil {
+		return err
+	}
+
+	if user.IsAdmin() {
+		fmt.Fprintf(out, "%s is already an administrator\n", user.Email)
+		return nil
+	}
+
+	err = users.SetRole(ctx, user.ID, models.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	// there is no administrator to record for the command line
+	err = audit.Insert(ctx, 0, models.ActionPromoteUser, user.ID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "%s is now an administrator\n", user.Email)
+	return nil
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lobre/doodle/pkg/models"
+	"github.com/lobre/doodle/pkg/models/memory"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Anonymous", "", "/admin/users", http.StatusSeeOther, nil},
+		{"Regular user", "alice@example.com", "/admin/users", http.StatusForbidden, nil},
+		{"Users", "carol@example.com", "/admin/users", http.StatusOK, []byte("bob@example.com")},
+		{"Search users", "carol@example.com", "/admin/users?q=ALICE", http.StatusOK, []byte("alice@example.com")},
+		{"Invalid page of users", "carol@example.com", "/admin/users?after=first", http.StatusNotFound, nil},
+		{"Events", "carol@example.com", "/admin/events", http.StatusOK, []byte("Cheap watches")},
+		{"Invalid page of events", "carol@example.com", "/admin/events?after=nowhere", http.StatusNotFound, nil},
+		{"Invalid period", "carol@example.com", "/admin/events?when=soon", http.StatusOK, []byte("This field is invalid")},
+		{"Audit trail", "carol@example.com", "/admin/audit", http.StatusOK, []byte(models.ActionDeactivateUser)},
+		{"Home", "carol@example.com", "/admin", http.StatusSeeOther, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.login(t, tt.email, "")
+			}
+
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAdminActions(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+		wantFlash    []byte
+	}{
+		{"Deactivate", "/admin/users/2/deactivate", http.StatusSeeOther, "/admin/users?q=bob%40example.com", []byte("The account of Bob has been deactivated.")},
+		{"Deactivate last administrator", "/admin/users/4/deactivate", http.StatusSeeOther, "/admin/users?q=carol%40example.com", []byte("Carol is the last active administrator.")},
+		{"Activate", "/admin/users/2/activate", http.StatusSeeOther, "/admin/users?q=bob%40example.com", []byte("The account of Bob has been activated.")},
+		{"Promote", "/admin/users/2/promote", http.StatusSeeOther, "/admin/users?q=bob%40example.com", []byte("Bob is now an administrator.")},
+		{"Demote last administrator", "/admin/users/4/demote", http.StatusSeeOther, "/admin/users?q=carol%40example.com", []byte("Carol is the last active administrator.")},
+		{"Reset", "/admin/users/2/reset", http.StatusSeeOther, "/admin/users?q=bob%40example.com", []byte("A link to reset their password has been sent to bob@example.com.")},
+		{"Unknown user", "/admin/users/99/promote", http.StatusNotFound, "", nil},
+		{"Hide", "/admin/events/1/hide", http.StatusSeeOther, "/admin/events?q=Music+festival", []byte("has been hidden.")},
+		{"Show", "/admin/events/4/show", http.StatusSeeOther, "/admin/events?q=Cheap+watches", []byte("is visible again.")},
+		{"Hide past", "/admin/events/3/hide", http.StatusSeeOther, "/admin/events?q=Winter+concert&when=past", []byte("has been hidden.")},
+		{"Delete", "/admin/events/1/delete", http.StatusSeeOther, "/admin/events", []byte("has been deleted.")},
+		{"Unknown event", "/admin/events/99/delete", http.StatusNotFound, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, "carol@example.com", "")
+
+			_, _, body := ts.get(t, "/admin/users")
+
+			form := url.Values{}
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, header, _ := ts.postForm(t, tt.urlPath, form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if location := header.Get("Location"); location != tt.wantLocation {
+				t.Errorf("want redirection to %q; got %q", tt.wantLocation, location)
+			}
+
+			if tt.wantFlash != nil {
+				_, _, body = ts.get(t, tt.wantLocation)
+				if !bytes.Contains(body, tt.wantFlash) {
+					t.Errorf("want body to contain %q", tt.wantFlash)
+				}
+			}
+		})
+	}
+
+	t.Run("Without CSRF token", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		ts.login(t, "carol@example.com", "")
+
+		code, _, _ := ts.postForm(t, "/admin/users/2/deactivate", url.Values{})
+		if code != http.StatusBadRequest {
+			t.Errorf("want %d; got %d", http.StatusBadRequest, code)
+		}
+	})
+}
+
+func TestHiddenEvent(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Anonymous", "", "/event/4", http.StatusNotFound, nil},
+		{"Owner", "bob@example.com", "/event/4", http.StatusNotFound, nil},
+		{"Administrator", "carol@example.com", "/event/4", http.StatusOK, []byte("hidden by an administrator")},
+		{"Export", "", "/event/4.ics", http.StatusNotFound, nil},
+		{"API", "", "/api/v1/events/4", http.StatusNotFound, []byte("Event not found")},
+		{"API administrator", "carol@example.com", "/api/v1/events/4", http.StatusOK, []byte("Cheap watches")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.login(t, tt.email, "")
+			}
+
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAdminFlow(t *testing.T) {
+	app := newMemoryApplication(t)
+
+	admin := newTestServer(t, app.routes())
+	defer admin.Close()
+	user := newTestServer(t, app.routes())
+	defer user.Close()
+
+	admin.signup(t, app, "Alice", "alice@example.com", "validPa$$word")
+	user.signup(t, app, "Bob", "bob@example.com", "validPa$$word")
+
+	var out strings.Builder
+	if err := runPromote(app.userStore, app.auditStore, []string{"alice@example.com"}, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	admin.login(t, "alice@example.com", "validPa$$word")
+	user.login(t, "bob@example.com", "validPa$$word")
+
+	when := time.Now().Add(24 * time.Hour)
+	slots := []*models.Slot{{Start: when, End: when.Add(time.Hour)}}
+
+	id, err := app.eventStore.Insert(context.Background(), 2, "Cheap watches", "Buy now!", when, "", slots)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, body := admin.get(t, "/admin/events?q=watches")
+	csrfToken := extractCSRFToken(t, body)
+
+	// post sends an action of the administrator, which must succeed
+	post := func(urlPath string) {
+		t.Helper()
+
+		code, _, _ := admin.postForm(t, urlPath, url.Values{"csrf_token": {csrfToken}})
+		if code != http.StatusSeeOther {
+			t.Fatalf("want %s to succeed; got %d", urlPath, code)
+		}
+	}
+
+	post(fmt.Sprintf("/admin/events/%d/hide", id))
+
+	code, _, _ := user.get(t, fmt.Sprintf("/event/%d", id))
+	if code != http.StatusNotFound {
+		t.Errorf("want hidden event to be gone for its owner; got %d", code)
+	}
+	_, _, body = user.get(t, "/events")
+	if bytes.Contains(body, []byte("Cheap watches")) {
+		t.Errorf("want hidden event to be left out of the listing")
+	}
+	_, _, body = admin.get(t, "/admin/events?q=watches")
+	if !bytes.Contains(body, []byte("Cheap watches")) {
+		t.Errorf("want hidden event to be listed for administrators")
+	}
+
+	post("/admin/users/2/deactivate")
+
+	code, _, _ = user.get(t, "/user/account")
+	if code != http.StatusSeeOther {
+		t.Errorf("want deactivated user to be logged out; got %d", code)
+	}
+
+	before := len(sentMails(app))
+
+	post("/admin/users/2/reset")
+	if len(sentMails(app)) != before {
+		t.Errorf("want no reset link to be sent to inactive users")
+	}
+
+	post("/admin/users/2/activate")
+	post("/admin/users/2/reset")
+
+	mails := sentMails(app)[before:]
+	if len(mails) != 1 || mails[0].To != "bob@example.com" {
+		t.Fatalf("want a reset link to be sent to bob@example.com; got %d mails", len(mails))
+	}
+	if link := mailLink(t, mails[0]); link.Path != "/user/password/reset" {
+		t.Errorf("want link to reset the password; got %s", link)
+	}
+
+	post(fmt.Sprintf("/admin/events/%d/delete", id))
+
+	// the last administrator cannot be demoted
+	post("/admin/users/1/demote")
+
+	_, _, body = admin.get(t, "/admin/audit")
+	actions := []models.Action{
+		models.ActionPromoteUser,
+		models.ActionHideEvent,
+		models.ActionDeactivateUser,
+		models.ActionActivateUser,
+		models.ActionResetUser,
+		models.ActionDeleteEvent,
+	}
+	for _, action := range actions {
+		if !bytes.Contains(body, []byte(action)) {
+			t.Errorf("want %s to be in the audit trail", action)
+		}
+	}
+	if bytes.Count(body, []byte(models.ActionResetUser)) != 1 || bytes.Contains(body, []byte(models.ActionDemoteUser)) {
+		t.Errorf("want refused actions to be left out of the audit trail")
+	}
+}
+
+func TestRunPromote(t *testing.T) {
+	db := memory.New()
+	users := &memory.UserStore{DB: db}
+	audit := &memory.AuditStore{DB: db}
+
+	if err := users.Insert(context.Background(), "Alice", "alice@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantOut string
+		wantErr string
+	}{
+		{"Missing email", []string{}, "", "usage: promote EMAIL"},
+		{"Unknown email", []string{"bob@example.com"}, "", `no user has the email "bob@example.com"`},
+		{"Promote", []string{"alice@example.com"}, "alice@example.com is now an administrator\n", ""},
+		{"Already promoted", []string{"alice@example.com"}, "alice@example.com is already an administrator\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+
+			err := runPromote(users, audit, tt.args, &out)
+			if (err == nil && tt.wantErr != "") || (err != nil && err.Error() != tt.wantErr) {
+				t.Errorf("want error %q; got %v", tt.wantErr, err)
+			}
+
+			if out.String() != tt.wantOut {
+				t.Errorf("want output %q; got %q", tt.wantOut, out.String())
+			}
+		})
+	}
+
+	page, err := audit.List(context.Background(), 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Entries) != 1 || page.Entries[0].Action != models.ActionPromoteUser {
+		t.Errorf("want the promotion to be in the audit trail once; got %d entries", len(page.Entries))
+	}
+}
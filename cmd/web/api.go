@@ -0,0 +1,451 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lobre/doodle/pkg/forms"
+	"github.com/lobre/doodle/pkg/models"
+)
+
+// maxJSONSize is the maximum size of the JSON bodies accepted by the API.
+const maxJSONSize = 1 << 20
+
+// The types below are the JSON representations of the models
+// exposed by the API.
+
+type apiSlot struct {
+	ID       int       `json:"id"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Yes      int       `json:"yes"`
+	IfNeedBe int       `json:"if_need_be"`
+	No       int       `json:"no"`
+}
+
+type apiParticipant struct {
+	ID      int                   `json:"id"`
+	Name    string                `json:"name"`
+	Updated time.Time             `json:"updated"`
+	Answers map[int]models.Answer `json:"answers"`
+}
+
+type apiEvent struct {
+	ID           int               `json:"id"`
+	URL          string            `json:"url"`
+	Title        string            `json:"title"`
+	Desc         string            `json:"desc"`
+	Time         time.Time         `json:"time"`
+	TimeZone     string            `json:"time_zone,omitempty"`
+	Status       models.Status     `json:"status"`
+	FinalSlotID  int               `json:"final_slot_id,omitempty"`
+	Slots        []*apiSlot        `json:"slots,omitempty"`
+	Participants []*apiParticipant `json:"participants,omitempty"`
+}
+
+// newAPIEvent converts an event to its JSON representation.
+// Participants are optional and only given when showing a single event.
+func (app *application) newAPIEvent(evt *models.Event, participants []*models.Participant) *apiEvent {
+	e := &apiEvent{
+		ID:          evt.ID,
+		URL:         fmt.Sprintf("%s/event/%d", app.publicURL, evt.ID),
+		Title:       evt.Title,
+		Desc:        evt.Desc,
+		Time:        evt.Time,
+		TimeZone:    evt.TimeZone,
+		Status:      evt.Status,
+		FinalSlotID: evt.FinalSlotID,
+	}
+
+	tallies := models.Tallies(participants)
+
+	for _, s := range evt.Slots {
+		slot := &apiSlot{ID: s.ID, Start: s.Start, End: s.End}
+		if t, ok := tallies[s.ID]; ok {
+			slot.Yes, slot.IfNeedBe, slot.No = t.Yes, t.IfNeedBe, t.No
+		}
+		e.Slots = append(e.Slots, slot)
+	}
+
+	for _, p := range participants {
+		e.Participants = append(e.Participants, &apiParticipant{
+			ID:      p.ID,
+			Name:    p.Name,
+			Updated: p.Updated,
+			Answers: p.Answers,
+		})
+	}
+
+	return e
+}
+
+// The types below are the JSON bodies accepted by the API.
+
+type apiEventInput struct {
+	Title    string `json:"title"`
+	Desc     string `json:"desc"`
+	Time     string `json:"time"`
+	TimeZone string `json:"time_zone"`
+	Slots    []struct {
+		Start string `json:"start"`
+		End   string `json:"end"`
+	} `json:"slots"`
+}
+
+// apiEditInput only has the fields of an event that can be edited, so that
+// the others are refused rather than ignored.
+type apiEditInput struct {
+	Title string `json:"title"`
+	Desc  string `json:"desc"`
+}
+
+type apiVoteInput struct {
+	Name    string                `json:"name"`
+	Answers map[int]models.Answer `json:"answers"`
+}
+
+func (app *application) apiListEvents(w http.ResponseWriter, r *http.Request) {
+	// the dates of the filter are interpreted in UTC for API clients
+	form := forms.New(r.URL.Query())
+
+	f, ok := app.eventFilter(r, form)
+	if !ok {
+		app.apiValidationError(w, form)
+		return
+	}
+
+	page, err := app.eventStore.List(r.Context(), f)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	list := []*apiEvent{}
+	for _, evt := range page.Events {
+		list = append(list, app.newAPIEvent(evt, nil))
+	}
+
+	data := map[string]interface{}{"events": list}
+	if page.Next != nil {
+		data["next"] = page.Next.String()
+	}
+
+	app.writeJSON(w, http.StatusOK, data)
+}
+
+func (app *application) apiShowEvent(w http.ResponseWriter, r *http.Request) {
+	evt := app.apiEvent(w, r)
+	if evt == nil {
+		return
+	}
+
+	app.writeEvent(w, r, http.StatusOK, evt)
+}
+
+func (app *application) apiCreateEvent(w http.ResponseWriter, r *http.Request) {
+	var input apiEventInput
+	if !app.readJSON(w, r, &input) {
+		return
+	}
+
+	// the input goes through the same validation as the html form
+	form := forms.New(url.Values{
+		"title":     []string{input.Title},
+		"desc":      []string{input.Desc},
+		"time":      []string{input.Time},
+		"time_zone": []string{input.TimeZone},
+	})
+	for _, s := range input.Slots {
+		form.Add("slot_start", s.Start)
+		form.Add("slot_end", s.End)
+	}
+
+	app.setEventLocation(r, form)
+	slots := validateEvent(form)
+
+	if !form.Valid() {
+		app.apiValidationError(w, form)
+		return
+	}
+
+	id, err := app.eventStore.Insert(r.Context(), app.authenticatedUserID(r), form.Get("title"), form.Get("desc"), form.GetTime("time"), form.Get("time_zone"), slots)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	evt, err := app.eventStore.Get(r.Context(), id)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/events/%d", id))
+	app.writeEvent(w, r, http.StatusCreated, evt)
+}
+
+func (app *application) apiEditEvent(w http.ResponseWriter, r *http.Request) {
+	evt := app.apiOwnedEvent(w, r)
+	if evt == nil {
+		return
+	}
+
+	if evt.Past() {
+		app.apiError(w, http.StatusConflict, "This event has already happened")
+		return
+	}
+
+	var input apiEditInput
+	if !app.readJSON(w, r, &input) {
+		return
+	}
+
+	form := forms.New(url.Values{
+		"title": []string{input.Title},
+		"desc":  []string{input.Desc},
+	})
+	form.Required("title", "desc")
+	form.MaxLength("title", 100)
+
+	if !form.Valid() {
+		app.apiValidationError(w, form)
+		return
+	}
+
+	err := app.eventStore.Update(r.Context(), evt.ID, form.Get("title"), form.Get("desc"))
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	evt, err = app.eventStore.Get(r.Context(), evt.ID)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	app.writeEvent(w, r, http.StatusOK, evt)
+}
+
+func (app *application) apiDeleteEvent(w http.ResponseWriter, r *http.Request) {
+	evt := app.apiOwnedEvent(w, r)
+	if evt == nil {
+		return
+	}
+
+	err := app.eventStore.Delete(r.Context(), evt.ID)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) apiVoteEvent(w http.ResponseWriter, r *http.Request) {
+	evt := app.apiEvent(w, r)
+	if evt == nil {
+		return
+	}
+
+	if evt.Past() {
+		app.apiError(w, http.StatusConflict, "This event has already happened")
+		return
+	}
+
+	if evt.Status != models.StatusOpen {
+		app.apiError(w, http.StatusConflict, "This poll is not open anymore")
+		return
+	}
+
+	var input apiVoteInput
+	if !app.readJSON(w, r, &input) {
+		return
+	}
+
+	form := forms.New(url.Values{"name": []string{input.Name}})
+	for slotID, answer := range input.Answers {
+		form.Set(fmt.Sprintf("slot_%d", slotID), string(answer))
+	}
+
+	answers := validateVote(form, evt)
+
+	if !form.Valid() {
+		app.apiValidationError(w, form)
+		return
+	}
+
+	err := app.voteStore.Upsert(r.Context(), evt.ID, app.authenticatedUserID(r), strings.TrimSpace(form.Get("name")), answers)
+	if err != nil {
+		if errors.Is(err, models.ErrNameTaken) {
+			form.Errors.Add("name", "This name is taken by another participant")
+			app.apiValidationError(w, form)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	app.writeEvent(w, r, http.StatusOK, evt)
+}
+
+// The apiEvent helper works as the event helper, but sends errors as JSON.
+func (app *application) apiEvent(w http.ResponseWriter, r *http.Request) *models.Event {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.apiError(w, http.StatusNotFound, "Event not found")
+		return nil
+	}
+
+	evt, err := app.eventStore.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiError(w, http.StatusNotFound, "Event not found")
+		} else {
+			app.apiServerError(w, err)
+		}
+		return nil
+	}
+
+	if evt.Hidden && !app.isAdmin(r) {
+		app.apiError(w, http.StatusNotFound, "Event not found")
+		return nil
+	}
+
+	return evt
+}
+
+// The apiOwnedEvent helper works as the ownedEvent helper, but sends errors as JSON.
+func (app *application) apiOwnedEvent(w http.ResponseWriter, r *http.Request) *models.Event {
+	evt := app.apiEvent(w, r)
+	if evt == nil {
+		return nil
+	}
+
+	if !app.isOwner(r, evt) {
+		app.apiError(w, http.StatusForbidden, "Only the owner of the event can do this")
+		return nil
+	}
+
+	return evt
+}
+
+// The writeEvent helper sends an event along with its participants.
+func (app *application) writeEvent(w http.ResponseWriter, r *http.Request, status int, evt *models.Event) {
+	participants, err := app.voteStore.ForEvent(r.Context(), evt.ID)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	app.writeJSON(w, status, app.newAPIEvent(evt, participants))
+}
+
+// The writeJSON helper encodes data and sends it with the given status code.
+func (app *application) writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(js, '\n'))
+}
+
+// The readJSON helper decodes the body of the request into dst. If the body
+// is not valid, a bad request error is sent and false is returned.
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONSize)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		app.apiError(w, http.StatusBadRequest, fmt.Sprintf("Invalid JSON body: %s", err))
+		return false
+	}
+
+	if dec.More() {
+		app.apiError(w, http.StatusBadRequest, "Invalid JSON body: it must only contain a single value")
+		return false
+	}
+
+	return true
+}
+
+// The apiError helper sends an error message as JSON.
+func (app *application) apiError(w http.ResponseWriter, status int, message string) {
+	app.writeJSON(w, status, map[string]string{"error": message})
+}
+
+// The apiServerError helper works as the serverError helper, but sends the
+// generic error as JSON.
+func (app *application) apiServerError(w http.ResponseWriter, err error) {
+	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
+	app.errorLog.Output(2, trace)
+
+	status := serverErrorStatus(err)
+	app.apiError(w, status, http.StatusText(status))
+}
+
+// The apiValidationError helper sends the errors of a form, indexed by field.
+func (app *application) apiValidationError(w http.ResponseWriter, form *forms.Form) {
+	app.writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+		"error":  "Validation failed",
+		"fields": form.Errors,
+	})
+}
+
+// requireAPIAuthentication sends an unauthorized error if the request is
+// not authenticated, as API clients cannot follow a redirection to the login page.
+func (app *application) requireAPIAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticated(r) {
+			app.apiError(w, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAPIVerification sends a forbidden error if the authenticated user
+// has not confirmed their email address yet. It must come after
+// requireAPIAuthentication.
+func (app *application) requireAPIVerification(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.authenticatedUser(r).Verified {
+			app.apiError(w, http.StatusForbidden, "Email address not confirmed")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireJSON rejects requests carrying a body that is not declared as JSON.
+// As browsers cannot send such requests across sites without a CORS preflight,
+// this also protects the API against CSRF when authenticated by the session.
+func (app *application) requireJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodPatch {
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || mediaType != "application/json" {
+				app.apiError(w, http.StatusUnsupportedMediaType, "The body must be sent as application/json")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
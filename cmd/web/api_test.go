@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAPI(t *testing.T) {
+	future := time.Now().Add(72 * time.Hour).UTC()
+
+	validEvent := fmt.Sprintf(`{"title": "Band rehearsal", "desc": "Bring your instrument", "time": %q,
+		"slots": [{"start": %q, "end": %q}]}`,
+		future.Format(time.RFC3339), future.Add(-2*time.Hour).Format(time.RFC3339), future.Add(-time.Hour).Format(time.RFC3339))
+
+	tests := []struct {
+		name     string
+		email    string
+		method   string
+		urlPath  string
+		body     string
+		wantCode int
+		wantBody []byte
+	}{
+		{"List", "", http.MethodGet, "/api/v1/events", "", http.StatusOK, []byte(`"title":"Music festival"`)},
+		{"List invalid cursor", "", http.MethodGet, "/api/v1/events?after=nowhere", "", http.StatusUnprocessableEntity, []byte(`"after"`)},
+		{"List own unauthenticated", "", http.MethodGet, "/api/v1/events?owner=me", "", http.StatusUnprocessableEntity, []byte(`"owner"`)},
+		{"List own", "bob@example.com", http.MethodGet, "/api/v1/events?owner=me", "", http.StatusOK, []byte(`"events":[]`)},
+		{"Show", "", http.MethodGet, "/api/v1/events/1", "", http.StatusOK, []byte(`"name":"Bob"`)},
+		{"Show non-existent", "", http.MethodGet, "/api/v1/events/2", "", http.StatusNotFound, []byte(`"error"`)},
+		{"Show with database timeout", "", http.MethodGet, "/api/v1/events/503", "", http.StatusServiceUnavailable, []byte(`"error"`)},
+		{"Create unauthenticated", "", http.MethodPost, "/api/v1/events", validEvent, http.StatusUnauthorized, nil},
+		{"Create", "alice@example.com", http.MethodPost, "/api/v1/events", validEvent, http.StatusCreated, []byte(`"id":1`)},
+		{"Create invalid", "alice@example.com", http.MethodPost, "/api/v1/events", `{"title": ""}`, http.StatusUnprocessableEntity, []byte(`"title":["This field cannot be blank"]`)},
+		{"Create malformed", "alice@example.com", http.MethodPost, "/api/v1/events", `{"title":`, http.StatusBadRequest, nil},
+		{"Create unknown field", "alice@example.com", http.MethodPost, "/api/v1/events", `{"name": "x"}`, http.StatusBadRequest, nil},
+		{"Edit", "alice@example.com", http.MethodPut, "/api/v1/events/1", `{"title": "New", "desc": "New"}`, http.StatusOK, nil},
+		{"Edit slots", "alice@example.com", http.MethodPut, "/api/v1/events/1", `{"title": "New", "desc": "New", "slots": []}`, http.StatusBadRequest, []byte(`"error"`)},
+		{"Edit not owner", "bob@example.com", http.MethodPut, "/api/v1/events/1", `{"title": "New", "desc": "New"}`, http.StatusForbidden, nil},
+		{"Delete not owner", "bob@example.com", http.MethodDelete, "/api/v1/events/1", "", http.StatusForbidden, nil},
+		{"Delete", "alice@example.com", http.MethodDelete, "/api/v1/events/1", "", http.StatusNoContent, nil},
+		{"Vote", "", http.MethodPost, "/api/v1/events/1/votes", `{"name": "Carol", "answers": {"1": "yes", "2": "no"}}`, http.StatusOK, nil},
+		{"Vote past", "", http.MethodPost, "/api/v1/events/3/votes", `{"name": "Carol", "answers": {"3": "yes"}}`, http.StatusConflict, []byte(`already happened`)},
+		{"Edit past", "alice@example.com", http.MethodPut, "/api/v1/events/3", `{"title": "New", "desc": "New"}`, http.StatusConflict, []byte(`already happened`)},
+		{"Vote invalid", "", http.MethodPost, "/api/v1/events/1/votes", `{"name": "Carol", "answers": {"1": "maybe"}}`, http.StatusUnprocessableEntity, []byte(`"slot_2"`)},
+		{"Vote as another user", "bob@example.com", http.MethodPost, "/api/v1/events/1/votes", `{"name": "Alice", "answers": {"1": "yes", "2": "no"}}`, http.StatusUnprocessableEntity, []byte(`"name":["This name is taken by another participant"]`)},
+		{"Vote as oneself", "alice@example.com", http.MethodPost, "/api/v1/events/1/votes", `{"name": "Alice", "answers": {"1": "yes", "2": "no"}}`, http.StatusOK, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.login(t, tt.email, "")
+			}
+
+			code, header, body := ts.do(t, tt.method, tt.urlPath, tt.body)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d (%s)", tt.wantCode, code, body)
+			}
+
+			if code != http.StatusNoContent && header.Get("Content-Type") != "application/json" {
+				t.Errorf("want json; got %q", header.Get("Content-Type"))
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q; got %s", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestAPIRequireJSON(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	ts.login(t, "alice@example.com", "")
+
+	rs, err := ts.Client().Post(ts.URL+"/api/v1/events", "text/plain", bytes.NewBufferString(`{"title": "x"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	if rs.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("want %d; got %d", http.StatusUnsupportedMediaType, rs.StatusCode)
+	}
+}
+
+func TestAPITokenAuthentication(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		method   string
+		urlPath  string
+		header   string
+		wantCode int
+	}{
+		{"Valid token", http.MethodDelete, "/api/v1/events/1", "Bearer alice-api-token", http.StatusNoContent},
+		{"Token of another user", http.MethodDelete, "/api/v1/events/1", "Bearer bob-api-token", http.StatusForbidden},
+		{"Invalid token", http.MethodGet, "/api/v1/events", "Bearer wrong", http.StatusUnauthorized},
+		{"Other scheme", http.MethodGet, "/api/v1/events", "Basic YWxpY2U6cGFzcw==", http.StatusUnauthorized},
+		{"Form without CSRF token", http.MethodPost, "/event/1/delete", "Bearer alice-api-token", http.StatusSeeOther},
+		{"Form with invalid token", http.MethodPost, "/event/1/delete", "Bearer wrong", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.urlPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", tt.header)
+
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rs.Body.Close()
+
+			if rs.StatusCode != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, rs.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// The types below are the JSON representations of the data of a user
+// in the archive of their account. Events reuse the ones of the API.
+
+type exportProfile struct {
+	Name     string    `json:"name"`
+	Email    string    `json:"email"`
+	Verified bool      `json:"verified"`
+	Created  time.Time `json:"created"`
+	TimeZone string    `json:"time_zone,omitempty"`
+}
+
+type exportVote struct {
+	Event *apiEvent       `json:"event"`
+	Vote  *apiParticipant `json:"vote"`
+}
+
+type exportToken struct {
+	Name     string     `json:"name"`
+	Created  time.Time  `json:"created"`
+	LastUsed *time.Time `json:"last_used,omitempty"`
+}
+
+// exportAccount sends a ZIP archive holding all the data of the current
+// user as JSON files: their profile, the events they created, the votes
+// they cast while logged in, and their API tokens without the secrets.
+func (app *application) exportAccount(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+
+	events, err := app.eventStore.ForUser(r.Context(), user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	created := []*apiEvent{}
+	votes := []*exportVote{}
+
+	for _, evt := range events {
+		participants, err := app.voteStore.ForEvent(r.Context(), evt.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		// only the tallies are kept, as the names of the other
+		// participants are not part of the data of the user
+		e := app.newAPIEvent(evt, participants)
+		e.Participants = nil
+
+		if evt.UserID == user.ID {
+			created = append(created, e)
+		}
+
+		for _, p := range participants {
+			if p.UserID == user.ID {
+				votes = append(votes, &exportVote{
+					Event: e,
+					Vote:  &apiParticipant{ID: p.ID, Name: p.Name, Updated: p.Updated, Answers: p.Answers},
+				})
+			}
+		}
+	}
+
+	tokens, err := app.tokenStore.ForUser(r.Context(), user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	exportTokens := []*exportToken{}
+	for _, t := range tokens {
+		et := &exportToken{Name: t.Name, Created: t.Created}
+		if !t.LastUsed.IsZero() {
+			et.LastUsed = &t.LastUsed
+		}
+		exportTokens = append(exportTokens, et)
+	}
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", &exportProfile{
+			Name:     user.Name,
+			Email:    user.Email,
+			Verified: user.Verified,
+			Created:  user.Created,
+			TimeZone: user.TimeZone,
+		}},
+		{"events.json", created},
+		{"votes.json", votes},
+		{"tokens.json", exportTokens},
+	}
+
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "\t")
+		if err = enc.Encode(f.data); err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	if err = zw.Close(); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	filename := fmt.Sprintf("doodle-%s.zip", time.Now().Format("2006-01-02"))
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	buf.WriteTo(w)
+}
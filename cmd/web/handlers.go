@@ -4,9 +4,14 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/lobre/doodle/pkg/forms"
+	"github.com/lobre/doodle/pkg/ical"
 	"github.com/lobre/doodle/pkg/models"
 )
 
@@ -15,7 +20,7 @@ func ping(w http.ResponseWriter, r *http.Request) {
 }
 
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
-	events, err := app.eventStore.Upcoming()
+	events, err := app.eventStore.Upcoming(r.Context())
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -26,35 +31,744 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (app *application) listEvents(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(r.URL.Query())
+	form.Location = app.location(r)
+
+	f, ok := app.eventFilter(r, form)
+	if !ok {
+		app.render(w, r, "events.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	page, err := app.eventStore.List(r.Context(), f)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	td := &templateData{Form: form, Events: page.Events}
+
+	if page.Next != nil {
+		query := r.URL.Query()
+		query.Set("after", page.Next.String())
+		td.NextPage = "/events?" + query.Encode()
+	}
+
+	app.render(w, r, "events.page.tmpl", td)
+}
+
+func (app *application) archive(w http.ResponseWriter, r *http.Request) {
+	f := models.EventFilter{Past: true, Desc: true, Limit: archivePageSize}
+
+	if after := r.URL.Query().Get("after"); after != "" {
+		cursor, err := models.ParseCursor(after)
+		if err != nil {
+			app.notFound(w)
+			return
+		}
+		f.After = cursor
+	}
+
+	page, err := app.eventStore.List(r.Context(), f)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	td := &templateData{Archive: groupByMonth(page.Events, app.location(r))}
+
+	if page.Next != nil {
+		td.NextPage = "/archive?after=" + url.QueryEscape(page.Next.String())
+	}
+
+	app.render(w, r, "archive.page.tmpl", td)
+}
+
+func (app *application) searchEvents(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(r.URL.Query())
+	form.MaxLength("q", 200)
+
+	page := 1
+	if p := form.Get("page"); p != "" {
+		var err error
+		page, err = strconv.Atoi(p)
+		if err != nil || page < 1 {
+			app.notFound(w)
+			return
+		}
+	}
+
+	td := &templateData{Form: form, SearchTerms: models.SearchTerms(form.Get("q"))}
+
+	if !form.Valid() || len(td.SearchTerms) == 0 {
+		app.render(w, r, "search.page.tmpl", td)
+		return
+	}
+
+	results, err := app.eventStore.Search(r.Context(), form.Get("q"), (page-1)*searchPageSize, searchPageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	td.Events = results.Events
+
+	query := r.URL.Query()
+	if results.More {
+		query.Set("page", strconv.Itoa(page+1))
+		td.NextPage = "/search?" + query.Encode()
+	}
+	if page > 1 {
+		query.Set("page", strconv.Itoa(page-1))
+		td.PrevPage = "/search?" + query.Encode()
+	}
+
+	app.render(w, r, "search.page.tmpl", td)
+}
+
 func (app *application) showEvent(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
-	if err != nil || id < 1 {
-		app.notFound(w)
+	evt := app.event(w, r)
+	if evt == nil {
+		return
+	}
+
+	// prefill the vote form when a participant wants to change their answers
+	form := forms.New(url.Values{})
+	form.Set("edit", r.URL.Query().Get("edit"))
+
+	app.renderEvent(w, r, evt, form)
+}
+
+func (app *application) voteEvent(w http.ResponseWriter, r *http.Request) {
+	evt := app.event(w, r)
+	if evt == nil {
+		return
+	}
+
+	if evt.Past() {
+		app.session.Put(r, "flash", "This event has already happened.")
+		http.Redirect(w, r, fmt.Sprintf("/event/%d", evt.ID), http.StatusSeeOther)
+		return
+	}
+
+	if evt.Status != models.StatusOpen {
+		app.session.Put(r, "flash", "This poll is not open anymore.")
+		http.Redirect(w, r, fmt.Sprintf("/event/%d", evt.ID), http.StatusSeeOther)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	answers := validateVote(form, evt)
+
+	if !form.Valid() {
+		app.renderEvent(w, r, evt, form)
+		return
+	}
+
+	err = app.voteStore.Upsert(r.Context(), evt.ID, app.authenticatedUserID(r), strings.TrimSpace(form.Get("name")), answers)
+	if err != nil {
+		if errors.Is(err, models.ErrNameTaken) {
+			form.Errors.Add("name", "This name is taken by another participant")
+			app.renderEvent(w, r, evt, form)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.session.Put(r, "flash", "Your answers have been saved!")
+
+	http.Redirect(w, r, fmt.Sprintf("/event/%d", evt.ID), http.StatusSeeOther)
+}
+
+// validateVote checks the name of the participant and their answer for
+// each slot of the event, which are sent in fields named after the slot IDs.
+// The names given to the votes of erased users are reserved, so that nobody
+// can take these votes over.
+func validateVote(form *forms.Form, evt *models.Event) map[int]models.Answer {
+	form.Required("name")
+	form.MaxLength("name", 255)
+
+	name := strings.ToLower(strings.TrimSpace(form.Get("name")))
+	if strings.HasPrefix(name, strings.ToLower(models.ErasedName)) {
+		form.Errors.Add("name", "This name is reserved")
+	}
+
+	answers := map[int]models.Answer{}
+	for _, s := range evt.Slots {
+		field := fmt.Sprintf("slot_%d", s.ID)
+		form.Required(field)
+		form.PermittedValues(field, string(models.AnswerYes), string(models.AnswerIfNeedBe), string(models.AnswerNo))
+		answers[s.ID] = models.Answer(form.Get(field))
+	}
+
+	return answers
+}
+
+func (app *application) exportEvent(w http.ResponseWriter, r *http.Request) {
+	evt := app.event(w, r)
+	if evt == nil {
+		return
+	}
+
+	app.writeCalendar(w, &ical.Calendar{
+		Name:   evt.Title,
+		Events: app.icalEvents(evt),
+	}, fmt.Sprintf("event-%d.ics", evt.ID))
+}
+
+func (app *application) editEventForm(w http.ResponseWriter, r *http.Request) {
+	evt := app.editableEvent(w, r)
+	if evt == nil {
+		return
+	}
+
+	form := forms.New(url.Values{})
+	form.Set("title", evt.Title)
+	form.Set("desc", evt.Desc)
+
+	app.render(w, r, "edit.page.tmpl", &templateData{
+		Event: evt,
+		Form:  form,
+	})
+}
+
+func (app *application) editEvent(w http.ResponseWriter, r *http.Request) {
+	evt := app.editableEvent(w, r)
+	if evt == nil {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("title", "desc")
+	form.MaxLength("title", 100)
+
+	if !form.Valid() {
+		app.render(w, r, "edit.page.tmpl", &templateData{Event: evt, Form: form})
+		return
+	}
+
+	err = app.eventStore.Update(r.Context(), evt.ID, form.Get("title"), form.Get("desc"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Event successfully updated!")
+
+	http.Redirect(w, r, fmt.Sprintf("/event/%d", evt.ID), http.StatusSeeOther)
+}
+
+func (app *application) deleteEvent(w http.ResponseWriter, r *http.Request) {
+	evt := app.ownedEvent(w, r)
+	if evt == nil {
+		return
+	}
+
+	err := app.eventStore.Delete(r.Context(), evt.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Event successfully deleted!")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (app *application) closeEvent(w http.ResponseWriter, r *http.Request) {
+	evt := app.editableEvent(w, r)
+	if evt == nil {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	slotID, err := strconv.Atoi(r.PostForm.Get("slot"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	found := false
+	for _, s := range evt.Slots {
+		if s.ID == slotID {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.eventStore.Close(r.Context(), evt.ID, slotID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "The poll is closed and the final slot has been picked!")
+
+	http.Redirect(w, r, fmt.Sprintf("/event/%d", evt.ID), http.StatusSeeOther)
+}
+
+func (app *application) cancelEvent(w http.ResponseWriter, r *http.Request) {
+	evt := app.editableEvent(w, r)
+	if evt == nil {
+		return
+	}
+
+	err := app.eventStore.Cancel(r.Context(), evt.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "The event has been cancelled.")
+
+	http.Redirect(w, r, fmt.Sprintf("/event/%d", evt.ID), http.StatusSeeOther)
+}
+
+func (app *application) reopenEvent(w http.ResponseWriter, r *http.Request) {
+	evt := app.editableEvent(w, r)
+	if evt == nil {
+		return
+	}
+
+	err := app.eventStore.Reopen(r.Context(), evt.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "The poll is open again.")
+
+	http.Redirect(w, r, fmt.Sprintf("/event/%d", evt.ID), http.StatusSeeOther)
+}
+
+func (app *application) createEventForm(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "create.page.tmpl", &templateData{
+		Form:      forms.New(nil),
+		TimeZones: timeZones,
+	})
+}
+
+func (app *application) createEvent(w http.ResponseWriter, r *http.Request) {
+	// the form is sent as multipart when it contains a calendar to import
+	err := r.ParseMultipartForm(maxUploadSize)
+	if err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	app.setEventLocation(r, form)
+
+	if form.Get("import") != "" {
+		app.importSlots(w, r, form)
+		return
+	}
+
+	slots := validateEvent(form)
+
+	if !form.Valid() {
+		app.render(w, r, "create.page.tmpl", &templateData{Form: form, TimeZones: timeZones})
+		return
+	}
+
+	id, err := app.eventStore.Insert(r.Context(), app.authenticatedUserID(r), form.Get("title"), form.Get("desc"), form.GetTime("time"), form.Get("time_zone"), slots)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Event successfully created!")
+
+	http.Redirect(w, r, fmt.Sprintf("/event/%d", id), http.StatusSeeOther)
+}
+
+// setEventLocation checks the optional time zone of an event form, and sets
+// the location of the form to it. Otherwise, dates are interpreted in the
+// zone of the organiser.
+func (app *application) setEventLocation(r *http.Request, form *forms.Form) {
+	form.ValidTimeZone("time_zone")
+
+	form.Location = app.location(r)
+	if loc, err := time.LoadLocation(form.Get("time_zone")); err == nil && form.Get("time_zone") != "" {
+		form.Location = loc
+	}
+}
+
+// validateEvent checks the fields needed to create an event,
+// and returns its candidate slots. As the event becomes read-only once its
+// time has passed, that time cannot come before the end of any slot.
+func validateEvent(form *forms.Form) []*models.Slot {
+	form.Required("title", "desc", "time")
+	form.MaxLength("title", 100)
+	form.ValidDateTime("time")
+	form.FutureDateTime("time")
+	form.MaxHorizon("time", maxHorizon)
+	slots := parseSlots(form)
+
+	if t := form.GetTime("time"); !t.IsZero() {
+		for _, s := range slots {
+			if t.Before(s.End) {
+				form.Errors.Add("time", "This field cannot be before the end of the last slot")
+				break
+			}
+		}
+	}
+
+	return slots
+}
+
+// importSlots fills the candidate slots of the create form with the events
+// and the free periods of an uploaded iCalendar file. The form is rendered
+// again so that the organiser can review the slots before publishing.
+func (app *application) importSlots(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	form.Del("import")
+
+	render := func() {
+		app.render(w, r, "create.page.tmpl", &templateData{Form: form, TimeZones: timeZones})
+	}
+
+	file, _, err := r.FormFile("ics")
+	if err != nil {
+		form.Errors.Add("ics", "Please choose a calendar file")
+		render()
+		return
+	}
+	defer file.Close()
+
+	dec := ical.NewDecoder(file)
+	dec.Location = form.Location
+
+	cal, err := dec.Decode()
+	if err != nil {
+		if errors.Is(err, ical.ErrInvalid) {
+			form.Errors.Add("ics", "This file is not a valid calendar")
+			render()
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	imported := []*models.Slot{}
+	add := func(start, end time.Time) {
+		if end.After(start) && start.After(time.Now()) {
+			imported = append(imported, &models.Slot{Start: start, End: end})
+		}
+	}
+
+	for _, evt := range cal.Events {
+		if evt.Status != ical.StatusCancelled {
+			add(evt.Start, evt.End)
+		}
+	}
+
+	for _, p := range cal.Periods {
+		if p.Type == ical.FreeBusyFree {
+			add(p.Start, p.End)
+		}
+	}
+
+	if len(imported) == 0 {
+		form.Errors.Add("ics", "No upcoming slot found in this calendar")
+		render()
+		return
+	}
+
+	sort.Slice(imported, func(i, j int) bool {
+		return imported[i].Start.Before(imported[j].Start)
+	})
+
+	// keep the slots that have already been filled in
+	starts, ends := []string{}, []string{}
+	for _, row := range slotRows(form) {
+		if row[0] != "" || row[1] != "" {
+			starts, ends = append(starts, row[0]), append(ends, row[1])
+		}
+	}
+
+	for _, s := range imported {
+		if len(starts) >= maxSlots {
+			break
+		}
+		starts = append(starts, s.Start.In(form.Location).Format(forms.DateTimeLayout))
+		ends = append(ends, s.End.In(form.Location).Format(forms.DateTimeLayout))
+	}
+
+	form.Values["slot_start"], form.Values["slot_end"] = starts, ends
+
+	app.session.Put(r, "flash", "Slots imported from your calendar, review them before publishing.")
+	render()
+}
+
+// maxUploadSize is the maximum size of the calendar files that can be imported.
+const maxUploadSize = 1 << 20
+
+// maxHorizon is how far in the future an event can be scheduled.
+const maxHorizon = 365 * 24 * time.Hour
+
+// maxSlots is the maximum number of candidate slots an event can have.
+const maxSlots = 50
+
+// searchPageSize is the number of search results shown per page.
+const searchPageSize = 10
+
+// archivePageSize is the number of past events shown per page of the archive.
+const archivePageSize = 50
+
+// parseSlots reads the slot_start and slot_end fields of the form, which are
+// sent as parallel lists, and returns the corresponding candidate slots.
+// Values are interpreted in the location of the form.
+// Rows left completely blank are ignored. Any invalid row adds an error
+// to the "slots" field of the form.
+func parseSlots(form *forms.Form) []*models.Slot {
+	starts, ends := form.Values["slot_start"], form.Values["slot_end"]
+	if len(starts) != len(ends) {
+		form.Errors.Add("slots", "Each slot needs a start and an end")
+		return nil
+	}
+
+	slots := []*models.Slot{}
+
+	for i := range starts {
+		if starts[i] == "" && ends[i] == "" {
+			continue
+		}
+
+		start, err := form.ParseDateTime(starts[i])
+		if err != nil {
+			form.Errors.Add("slots", fmt.Sprintf("Slot %d has an invalid start", i+1))
+			return nil
+		}
+
+		end, err := form.ParseDateTime(ends[i])
+		if err != nil {
+			form.Errors.Add("slots", fmt.Sprintf("Slot %d has an invalid end", i+1))
+			return nil
+		}
+
+		if !end.After(start) {
+			form.Errors.Add("slots", fmt.Sprintf("Slot %d must end after it starts", i+1))
+			return nil
+		}
+
+		if !start.After(time.Now()) {
+			form.Errors.Add("slots", fmt.Sprintf("Slot %d must be in the future", i+1))
+			return nil
+		}
+
+		// the end comes after the start, which is then within the horizon too
+		if end.After(time.Now().Add(maxHorizon)) {
+			form.Errors.Add("slots", fmt.Sprintf("Slot %d cannot end more than %d days from now", i+1, maxHorizon/(24*time.Hour)))
+			return nil
+		}
+
+		slots = append(slots, &models.Slot{Start: start, End: end})
+	}
+
+	switch {
+	case len(slots) == 0:
+		form.Errors.Add("slots", "At least one slot must be proposed")
+	case len(slots) > maxSlots:
+		form.Errors.Add("slots", fmt.Sprintf("No more than %d slots can be proposed", maxSlots))
+	}
+
+	return slots
+}
+
+func (app *application) signupUserForm(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "signup.page.tmpl", &templateData{
+		Form: forms.New(nil),
+	})
+}
+
+func (app *application) signupUser(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("name", "email", "password")
+	form.MaxLength("name", 255)
+	form.MaxLength("email", 255)
+	form.MatchesPattern("email", forms.EmailRX)
+	form.MinLength("password", 10)
+
+	if !form.Valid() {
+		app.render(w, r, "signup.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	err = app.userStore.Insert(r.Context(), form.Get("name"), form.Get("email"), form.Get("password"))
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateEmail) {
+			form.Errors.Add("email", "Address is already in use")
+			app.render(w, r, "signup.page.tmpl", &templateData{Form: form})
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	// the account exists anyway, and the user can ask for another link
+	if err = app.sendVerification(r, form.Get("email")); err != nil {
+		app.errorLog.Printf("verification of %s: %s", form.Get("email"), err)
+	}
+
+	app.session.Put(r, "flash", "Your signup was successful. Please confirm your email address with the link we sent you, and log in.")
+
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+func (app *application) loginUserForm(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "login.page.tmpl", &templateData{
+		Form: forms.New(nil),
+	})
+}
+
+func (app *application) loginUser(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	id, err := app.userStore.Authenticate(r.Context(), form.Get("email"), form.Get("password"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.Errors.Add("generic", "Email or Password is incorrect")
+			app.render(w, r, "login.page.tmpl", &templateData{Form: form})
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	err = app.startSession(r, id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "You are now logged in.")
+
+	http.Redirect(w, r, "/event/create", http.StatusSeeOther)
+}
+
+func (app *application) logoutUser(w http.ResponseWriter, r *http.Request) {
+	app.endSession(r)
+	app.session.Put(r, "flash", "You've been logged out successfully!")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+const (
+	// verifyTokenLifetime is how long a link to confirm an address can be used.
+	verifyTokenLifetime = 48 * time.Hour
+	// verifyResendInterval is how long a user must wait before asking
+	// for another link, so that the application cannot be used to flood
+	// an inbox.
+	verifyResendInterval = 5 * time.Minute
+)
+
+// verifyEmail confirms the address the token was sent to. The user does
+// not need to be logged in, as the link may be opened on another device.
+func (app *application) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	_, err := app.verifStore.Verify(r.Context(), r.URL.Query().Get("token"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form := forms.New(url.Values{})
+			form.Errors.Add("token", "This link is invalid or has expired")
+			app.render(w, r, "verification.page.tmpl", &templateData{
+				Form: form,
+				User: app.authenticatedUser(r),
+			})
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.session.Put(r, "flash", "Your email address has been confirmed.")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (app *application) verificationPage(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "verification.page.tmpl", &templateData{
+		User: app.authenticatedUser(r),
+	})
+}
+
+// resendVerification sends a new link to confirm the address of the user,
+// unless one has been sent too recently.
+func (app *application) resendVerification(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+	if user.Verified {
+		app.session.Put(r, "flash", "Your email address is already confirmed.")
+		http.Redirect(w, r, "/user/verification", http.StatusSeeOther)
 		return
 	}
 
-	evt, err := app.eventStore.Get(id)
+	sent, err := app.verifStore.Sent(r.Context(), user.ID)
 	if err != nil {
-		if errors.Is(err, models.ErrNoRecord) {
-			app.notFound(w)
-		} else {
-			app.serverError(w, err)
-		}
+		app.serverError(w, err)
 		return
 	}
 
-	app.render(w, r, "show.page.tmpl", &templateData{
-		Event: evt,
-	})
+	if time.Since(sent) < verifyResendInterval {
+		app.session.Put(r, "flash", "A link has been sent recently. Please wait a few minutes before asking for another one.")
+		http.Redirect(w, r, "/user/verification", http.StatusSeeOther)
+		return
+	}
+
+	if err = app.sendVerification(r, user.Email); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "A new link has been sent to your email address.")
+
+	http.Redirect(w, r, "/user/verification", http.StatusSeeOther)
 }
 
-func (app *application) createEventForm(w http.ResponseWriter, r *http.Request) {
-	app.render(w, r, "create.page.tmpl", &templateData{
+// resetTokenLifetime is how long a link to reset a password can be used.
+const resetTokenLifetime = time.Hour
+
+func (app *application) forgotPasswordForm(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "forgot.page.tmpl", &templateData{
 		Form: forms.New(nil),
 	})
 }
 
-func (app *application) createEvent(w http.ResponseWriter, r *http.Request) {
+// forgotPassword emails a link to reset the password of the account with
+// the given address. The response is the same whether such an account
+// exists or not, so that it does not reveal who is registered.
+func (app *application) forgotPassword(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
 		app.clientError(w, http.StatusBadRequest)
@@ -62,33 +776,44 @@ func (app *application) createEvent(w http.ResponseWriter, r *http.Request) {
 	}
 
 	form := forms.New(r.PostForm)
-	form.Required("title", "desc", "time")
-	form.MaxLength("title", 100)
-	form.PermittedValues("time", "365", "7", "1")
+	form.Required("email")
+	form.MaxLength("email", 255)
+	form.MatchesPattern("email", forms.EmailRX)
 
 	if !form.Valid() {
-		app.render(w, r, "create.page.tmpl", &templateData{Form: form})
+		app.render(w, r, "forgot.page.tmpl", &templateData{Form: form})
 		return
 	}
 
-	id, err := app.eventStore.Insert(form.Get("title"), form.Get("desc"), form.Get("time"))
-	if err != nil {
+	err = app.sendPasswordReset(r, form.Get("email"), "Someone asked to reset the password of your Doodle account.")
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
 		app.serverError(w, err)
 		return
 	}
 
-	app.session.Put(r, "flash", "Event successfully created!")
+	app.session.Put(r, "flash", "If an account exists for this address, an email has been sent with a link to reset its password.")
 
-	http.Redirect(w, r, fmt.Sprintf("/event/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 }
 
-func (app *application) signupUserForm(w http.ResponseWriter, r *http.Request) {
-	app.render(w, r, "signup.page.tmpl", &templateData{
-		Form: forms.New(nil),
-	})
+func (app *application) resetPasswordForm(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(url.Values{})
+	form.Set("token", r.URL.Query().Get("token"))
+
+	err := app.resetStore.Check(r.Context(), form.Get("token"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.Errors.Add("token", "This link is invalid or has expired")
+		} else {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	app.render(w, r, "reset.page.tmpl", &templateData{Form: form})
 }
 
-func (app *application) signupUser(w http.ResponseWriter, r *http.Request) {
+func (app *application) resetPassword(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
 		app.clientError(w, http.StatusBadRequest)
@@ -96,40 +821,108 @@ func (app *application) signupUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	form := forms.New(r.PostForm)
-	form.Required("name", "email", "password")
+	form.Required("password")
+	form.MinLength("password", 10)
+
+	if !form.Valid() {
+		app.render(w, r, "reset.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	err = app.resetStore.Reset(r.Context(), form.Get("token"), form.Get("password"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.Errors.Add("token", "This link is invalid or has expired")
+			app.render(w, r, "reset.page.tmpl", &templateData{Form: form})
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.session.Put(r, "flash", "Your password has been changed. Please log in.")
+
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+func (app *application) accountPage(w http.ResponseWriter, r *http.Request) {
+	app.renderAccount(w, r, forms.New(url.Values{}))
+}
+
+func (app *application) changeName(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("name")
 	form.MaxLength("name", 255)
+
+	if !form.Valid() {
+		app.renderAccount(w, r, form)
+		return
+	}
+
+	err = app.userStore.SetName(r.Context(), app.authenticatedUserID(r), form.Get("name"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Your name has been changed.")
+
+	http.Redirect(w, r, "/user/account", http.StatusSeeOther)
+}
+
+// changeEmail changes the address of the user, who has to confirm the new
+// one before creating events again.
+func (app *application) changeEmail(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("email")
 	form.MaxLength("email", 255)
 	form.MatchesPattern("email", forms.EmailRX)
-	form.MinLength("password", 10)
 
 	if !form.Valid() {
-		app.render(w, r, "signup.page.tmpl", &templateData{Form: form})
+		app.renderAccount(w, r, form)
+		return
+	}
+
+	email := form.Get("email")
+	if email == app.authenticatedUser(r).Email {
+		http.Redirect(w, r, "/user/account", http.StatusSeeOther)
 		return
 	}
 
-	err = app.userStore.Insert(form.Get("name"), form.Get("email"), form.Get("password"))
+	err = app.userStore.SetEmail(r.Context(), app.authenticatedUserID(r), email)
 	if err != nil {
 		if errors.Is(err, models.ErrDuplicateEmail) {
 			form.Errors.Add("email", "Address is already in use")
-			app.render(w, r, "signup.page.tmpl", &templateData{Form: form})
+			app.renderAccount(w, r, form)
 		} else {
 			app.serverError(w, err)
 		}
 		return
 	}
 
-	app.session.Put(r, "flash", "Your signup was successful. Please log in.")
+	// the address has changed anyway, and the user can ask for another link
+	if err = app.sendVerification(r, email); err != nil {
+		app.errorLog.Printf("verification of %s: %s", email, err)
+	}
 
-	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
-}
+	app.session.Put(r, "flash", "Your email address has been changed. Please confirm it with the link we sent you.")
 
-func (app *application) loginUserForm(w http.ResponseWriter, r *http.Request) {
-	app.render(w, r, "login.page.tmpl", &templateData{
-		Form: forms.New(nil),
-	})
+	http.Redirect(w, r, "/user/account", http.StatusSeeOther)
 }
 
-func (app *application) loginUser(w http.ResponseWriter, r *http.Request) {
+func (app *application) changePassword(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
 		app.clientError(w, http.StatusBadRequest)
@@ -137,25 +930,254 @@ func (app *application) loginUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	form := forms.New(r.PostForm)
-	id, err := app.userStore.Authenticate(form.Get("email"), form.Get("password"))
+	form.Required("current_password", "new_password")
+	form.MinLength("new_password", 10)
+
+	if !form.Valid() {
+		app.renderAccount(w, r, form)
+		return
+	}
+
+	err = app.userStore.ChangePassword(r.Context(), app.authenticatedUserID(r), form.Get("current_password"), form.Get("new_password"))
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
-			form.Errors.Add("generic", "Email or Password is incorrect")
-			app.render(w, r, "login.page.tmpl", &templateData{Form: form})
+			form.Errors.Add("current_password", "Password is incorrect")
+			app.renderAccount(w, r, form)
 		} else {
 			app.serverError(w, err)
 		}
 		return
 	}
 
-	app.session.Put(r, "authenticatedUserID", id)
-	app.session.Put(r, "flash", "You are now logged in.")
+	// the other sessions have ended along with the old password
+	err = app.startSession(r, app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 
-	http.Redirect(w, r, "/event/create", http.StatusSeeOther)
+	app.session.Put(r, "flash", "Your password has been changed. Your other sessions and API tokens have been ended.")
+
+	http.Redirect(w, r, "/user/account", http.StatusSeeOther)
 }
 
-func (app *application) logoutUser(w http.ResponseWriter, r *http.Request) {
-	app.session.Remove(r, "authenticatedUserID")
-	app.session.Put(r, "flash", "You've been logged out successfully!")
+// deactivateAccount disables the account of the current user, once they
+// have confirmed with their password. Their data is kept. The last active
+// administrator cannot leave.
+func (app *application) deactivateAccount(w http.ResponseWriter, r *http.Request) {
+	form, ok := app.confirmPassword(w, r, "deactivate_password")
+	if !ok {
+		return
+	}
+	if !form.Valid() {
+		app.renderAccount(w, r, form)
+		return
+	}
+
+	err := app.userStore.Deactivate(r.Context(), app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrLastAdmin) {
+			form.Errors.Add("deactivate_password", "You are the last active administrator")
+			app.renderAccount(w, r, form)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.endSession(r)
+	app.session.Put(r, "flash", "Your account has been deactivated.")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// eraseAccount permanently deletes the current user and their events, once
+// they have confirmed with their password. Their votes on the events of
+// others are kept anonymously, so that these polls stay accurate. The last
+// active administrator cannot leave.
+func (app *application) eraseAccount(w http.ResponseWriter, r *http.Request) {
+	form, ok := app.confirmPassword(w, r, "erase_password")
+	if !ok {
+		return
+	}
+	if !form.Valid() {
+		app.renderAccount(w, r, form)
+		return
+	}
+
+	err := app.userStore.Erase(r.Context(), app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrLastAdmin) {
+			form.Errors.Add("erase_password", "You are the last active administrator")
+			app.renderAccount(w, r, form)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.endSession(r)
+	app.session.Put(r, "flash", "Your account and all your data have been deleted.")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (app *application) timeZoneForm(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(url.Values{})
+	form.Set("timezone", app.location(r).String())
+
+	app.render(w, r, "timezone.page.tmpl", &templateData{
+		Form:      form,
+		TimeZones: timeZones,
+	})
+}
+
+func (app *application) setTimeZone(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("timezone")
+	form.ValidTimeZone("timezone")
+
+	if !form.Valid() {
+		app.render(w, r, "timezone.page.tmpl", &templateData{Form: form, TimeZones: timeZones})
+		return
+	}
+
+	// the preference of authenticated users is kept in their profile,
+	// while the cookie is enough for anonymous visitors
+	if user := app.authenticatedUser(r); user != nil {
+		err = app.userStore.SetTimeZone(r.Context(), user.ID, form.Get("timezone"))
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "tz",
+		Value:    url.QueryEscape(form.Get("timezone")),
+		Path:     "/",
+		MaxAge:   365 * 24 * 60 * 60,
+		Secure:   app.isHTTPS,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	app.session.Put(r, "flash", "Your time zone has been saved.")
+
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
+
+func (app *application) tokensPage(w http.ResponseWriter, r *http.Request) {
+	app.renderTokens(w, r, forms.New(url.Values{}), "")
+}
+
+// createToken generates a new API token. It is rendered right away
+// instead of redirecting, as it is the only time it can be shown.
+func (app *application) createToken(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("name")
+	form.MaxLength("name", 100)
+
+	if !form.Valid() {
+		app.renderTokens(w, r, form, "")
+		return
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.tokenStore.Insert(r.Context(), app.authenticatedUserID(r), form.Get("name"), token)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.renderTokens(w, r, forms.New(url.Values{}), token)
+}
+
+func (app *application) revokeToken(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = app.tokenStore.Delete(r.Context(), id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.session.Put(r, "flash", "The token has been revoked.")
+
+	http.Redirect(w, r, "/user/tokens", http.StatusSeeOther)
+}
+
+func (app *application) calendarPage(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "calendar.page.tmpl", &templateData{
+		User: app.authenticatedUser(r),
+	})
+}
+
+// resetFeedToken generates a new secret for the calendar feed of the user.
+// The previous feed URL, if any, stops working.
+func (app *application) resetFeedToken(w http.ResponseWriter, r *http.Request) {
+	token, err := generateToken()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.userStore.SetFeedToken(r.Context(), app.authenticatedUserID(r), token)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "A new calendar feed URL has been generated.")
+
+	http.Redirect(w, r, "/user/calendar", http.StatusSeeOther)
+}
+
+func (app *application) calendarFeed(w http.ResponseWriter, r *http.Request) {
+	user, err := app.userStore.GetByFeedToken(r.Context(), r.URL.Query().Get(":token"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	events, err := app.eventStore.ForUser(r.Context(), user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	cal := &ical.Calendar{Name: "Doodle"}
+	for _, evt := range events {
+		cal.Events = append(cal.Events, app.icalEvents(evt)...)
+	}
+
+	app.writeCalendar(w, cal, "")
+}
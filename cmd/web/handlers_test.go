@@ -1,8 +1,22 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/lobre/doodle/pkg/forms"
+	"github.com/lobre/doodle/pkg/models"
 )
 
 func TestPing(t *testing.T) {
@@ -19,3 +33,1377 @@ func TestPing(t *testing.T) {
 		t.Errorf("want body to equal %q", "OK")
 	}
 }
+
+func TestParseSlots(t *testing.T) {
+	at := func(d time.Duration) string {
+		return time.Now().UTC().Add(d).Format(forms.DateTimeLayout)
+	}
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name      string
+		starts    []string
+		ends      []string
+		wantSlots int
+		wantValid bool
+	}{
+		{"Valid", []string{at(day), at(2 * day)}, []string{at(day + time.Hour), at(2*day + time.Hour)}, 2, true},
+		{"Blank rows ignored", []string{at(day), ""}, []string{at(day + time.Hour), ""}, 1, true},
+		{"No slot", []string{""}, []string{""}, 0, false},
+		{"Invalid start", []string{"tomorrow"}, []string{at(day)}, 0, false},
+		{"End before start", []string{at(day + time.Hour)}, []string{at(day)}, 0, false},
+		{"In the past", []string{"2000-01-02T10:00"}, []string{"2000-01-02T12:00"}, 0, false},
+		{"Beyond the horizon", []string{at(maxHorizon + day)}, []string{at(maxHorizon + day + time.Hour)}, 0, false},
+		{"Ending beyond the horizon", []string{at(maxHorizon - time.Hour)}, []string{at(maxHorizon + time.Hour)}, 0, false},
+		{"Mismatched lists", []string{at(day)}, []string{}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := forms.New(url.Values{
+				"slot_start": tt.starts,
+				"slot_end":   tt.ends,
+			})
+
+			slots := parseSlots(form)
+
+			if form.Valid() != tt.wantValid {
+				t.Errorf("want valid %t; got %t", tt.wantValid, form.Valid())
+			}
+
+			if tt.wantValid && len(slots) != tt.wantSlots {
+				t.Errorf("want %d slots; got %d", tt.wantSlots, len(slots))
+			}
+		})
+	}
+}
+
+func TestListEvents(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantBody []byte
+	}{
+		{"All", "/events", []byte("Music festival")},
+		{"Filtered", "/events?from=2030-01-01&to=2030-01-31&sort=desc", []byte("Music festival")},
+		{"Invalid date", "/events?from=tomorrow", []byte("This field is not a valid date")},
+		{"Reversed range", "/events?from=2030-01-31&to=2030-01-01", []byte("This field cannot be before the start date")},
+		{"Invalid sort", "/events?sort=random", []byte("This field is invalid")},
+		{"Invalid cursor", "/events?after=nowhere", []byte("This page does not exist")},
+		{"Own unauthenticated", "/events?owner=me", []byte("You must be logged in")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != http.StatusOK {
+				t.Errorf("want %d; got %d", http.StatusOK, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSearchEvents(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Match", "/search?q=music", http.StatusOK, []byte("<mark>Music</mark> festival")},
+		{"Match description", "/search?q=FUN", http.StatusOK, []byte("always <mark>fun</mark>.")},
+		{"No match", "/search?q=opera", http.StatusOK, []byte("No event matches your search.")},
+		{"Blank", "/search?q=", http.StatusOK, []byte("Search events:")},
+		{"Too long", "/search?q=" + strings.Repeat("a", 201), http.StatusOK, []byte("This field is too long")},
+		{"Invalid page", "/search?q=music&page=0", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestShowEvent(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Valid ID", "/event/1", http.StatusOK, []byte("Happening every year, and always fun.")},
+		{"Participant", "/event/1", http.StatusOK, []byte("Bob")},
+		{"Non-existent ID", "/event/2", http.StatusNotFound, nil},
+		{"Database timeout", "/event/503", http.StatusServiceUnavailable, nil},
+		{"Negative ID", "/event/-1", http.StatusNotFound, nil},
+		{"Decimal ID", "/event/1.23", http.StatusNotFound, nil},
+		{"String ID", "/event/foo", http.StatusNotFound, nil},
+		{"Empty ID", "/event/", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestVoteEvent(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	_, _, body := ts.get(t, "/event/1")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		voter     string
+		answer    string
+		csrfToken string
+		wantCode  int
+		wantBody  []byte
+	}{
+		{"Valid submission", "/event/1/vote", "Carol", "yes", csrfToken, http.StatusSeeOther, nil},
+		{"Empty name", "/event/1/vote", "", "yes", csrfToken, http.StatusOK, nil},
+		{"Invalid answer", "/event/1/vote", "Carol", "perhaps", csrfToken, http.StatusOK, nil},
+		{"Name of a user", "/event/1/vote", "Alice", "yes", csrfToken, http.StatusOK, []byte("This name is taken by another participant")},
+		{"Name of an erased user", "/event/1/vote", " deleted user #3", "yes", csrfToken, http.StatusOK, []byte("This name is reserved")},
+		{"Invalid CSRF Token", "/event/1/vote", "Carol", "yes", "wrongToken", http.StatusBadRequest, nil},
+		{"Non-existent event", "/event/2/vote", "Carol", "yes", csrfToken, http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", tt.voter)
+			form.Add("slot_1", tt.answer)
+			form.Add("slot_2", "no")
+			form.Add("csrf_token", tt.csrfToken)
+
+			code, _, body := ts.postForm(t, tt.urlPath, form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestPastEvent(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "")
+
+	code, _, body := ts.get(t, "/event/3")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("This event has happened")) {
+		t.Errorf("want past event to be shown with a banner")
+	}
+	if bytes.Contains(body, []byte("Save my answers")) || bytes.Contains(body, []byte("Reopen the poll")) {
+		t.Errorf("want past event to be read-only")
+	}
+
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name    string
+		method  string
+		urlPath string
+	}{
+		{"Vote", http.MethodPost, "/event/3/vote"},
+		{"Edit form", http.MethodGet, "/event/3/edit"},
+		{"Edit", http.MethodPost, "/event/3/edit"},
+		{"Close", http.MethodPost, "/event/3/close"},
+		{"Cancel", http.MethodPost, "/event/3/cancel"},
+		{"Reopen", http.MethodPost, "/event/3/reopen"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var code int
+			var header http.Header
+
+			if tt.method == http.MethodGet {
+				code, header, _ = ts.get(t, tt.urlPath)
+			} else {
+				form := url.Values{}
+				form.Add("name", "Carol")
+				form.Add("title", "New title")
+				form.Add("desc", "New description")
+				form.Add("slot", "3")
+				form.Add("csrf_token", csrfToken)
+
+				code, header, _ = ts.postForm(t, tt.urlPath, form)
+			}
+
+			if code != http.StatusSeeOther || header.Get("Location") != "/event/3" {
+				t.Errorf("want redirection to the event; got %d to %q", code, header.Get("Location"))
+			}
+		})
+	}
+
+	_, _, body = ts.get(t, "/event/3")
+	if !bytes.Contains(body, []byte("This event has already happened.")) {
+		t.Errorf("want flash message explaining the refusal")
+	}
+}
+
+func TestArchive(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/archive")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+
+	month := time.Now().Add(-24 * time.Hour).UTC().Format("January 2006")
+	if !bytes.Contains(body, []byte("<h3>"+month+"</h3>")) || !bytes.Contains(body, []byte("Winter concert")) {
+		t.Errorf("want past event to be listed under its month")
+	}
+	if bytes.Contains(body, []byte("Music festival")) {
+		t.Errorf("want upcoming event to be left out of the archive")
+	}
+
+	code, _, _ = ts.get(t, "/archive?after=nowhere")
+	if code != http.StatusNotFound {
+		t.Errorf("want %d for an invalid cursor; got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestCloseEvent(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	_, _, body := ts.get(t, "/event/1")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("slot", "1")
+	form.Add("csrf_token", csrfToken)
+
+	code, header, _ := ts.postForm(t, "/event/1/close", form)
+	if code != http.StatusSeeOther || header.Get("Location") != "/user/login" {
+		t.Errorf("want redirection to login; got %d to %q", code, header.Get("Location"))
+	}
+
+	ts.login(t, "alice@example.com", "")
+
+	_, _, body = ts.get(t, "/event/1")
+	csrfToken = extractCSRFToken(t, body)
+
+	if !bytes.Contains(body, []byte("Close the poll")) {
+		t.Errorf("want owner to be able to close the poll")
+	}
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		slot     string
+		wantCode int
+	}{
+		{"Valid slot", "/event/1/close", "1", http.StatusSeeOther},
+		{"Slot of another event", "/event/1/close", "42", http.StatusBadRequest},
+		{"Invalid slot", "/event/1/close", "foo", http.StatusBadRequest},
+		{"Non-existent event", "/event/2/close", "1", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("slot", tt.slot)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestEditEvent(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		title    string
+		wantCode int
+	}{
+		{"Owner", "alice@example.com", "/event/1/edit", "New title", http.StatusSeeOther},
+		{"Owner with blank title", "alice@example.com", "/event/1/edit", "", http.StatusOK},
+		{"Not owner", "bob@example.com", "/event/1/edit", "New title", http.StatusForbidden},
+		{"Non-existent event", "alice@example.com", "/event/2/edit", "New title", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, tt.email, "")
+
+			_, _, body := ts.get(t, "/event/1")
+
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("desc", "Some description")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestDeleteEvent(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		wantCode int
+	}{
+		{"Owner", "alice@example.com", http.StatusSeeOther},
+		{"Not owner", "bob@example.com", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, tt.email, "")
+
+			_, _, body := ts.get(t, "/event/1")
+
+			form := url.Values{}
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, _ := ts.postForm(t, "/event/1/delete", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestCreateEvent(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	ts.login(t, "alice@example.com", "")
+
+	_, _, body := ts.get(t, "/event/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	future := time.Now().Add(72 * time.Hour)
+
+	tests := []struct {
+		name     string
+		time     string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Valid", future.Format(forms.DateTimeLayout), http.StatusSeeOther, nil},
+		{"Legacy value", "365", http.StatusOK, []byte("not a valid date")},
+		{"Past", "2000-01-01T19:00", http.StatusOK, []byte("must be in the future")},
+		{"Too far", future.AddDate(2, 0, 0).Format(forms.DateTimeLayout), http.StatusOK, []byte("more than 365 days")},
+		{"Before the last slot", future.Add(-90 * time.Minute).Format(forms.DateTimeLayout), http.StatusOK, []byte("cannot be before the end of the last slot")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Band rehearsal")
+			form.Add("desc", "Bring your instrument")
+			form.Add("time", tt.time)
+			form.Add("slot_start", future.Add(-2*time.Hour).Format(forms.DateTimeLayout))
+			form.Add("slot_end", future.Add(-time.Hour).Format(forms.DateTimeLayout))
+			form.Add("csrf_token", csrfToken)
+
+			code, header, body := ts.postForm(t, "/event/create", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if code == http.StatusSeeOther && header.Get("Location") != "/event/1" {
+				t.Errorf("want redirection to /event/1; got %q", header.Get("Location"))
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestTimeZone(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	_, _, body := ts.get(t, "/timezone")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		timezone string
+		wantCode int
+	}{
+		{"Valid", "Europe/Paris", http.StatusSeeOther},
+		{"Unknown", "Europe/Atlantis", http.StatusOK},
+		{"Local", "Local", http.StatusOK},
+		{"Blank", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("timezone", tt.timezone)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/timezone", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			// a page answering a form is not reloaded to detect the time zone
+			if bytes.Contains(body, []byte("data-reloadable")) {
+				t.Errorf("want page not to be reloadable")
+			}
+		})
+	}
+
+	_, _, body = ts.get(t, "/event/1")
+	if !bytes.Contains(body, []byte("data-reloadable")) {
+		t.Errorf("want page to be reloadable")
+	}
+	if !bytes.Contains(body, []byte("Europe/Paris")) {
+		t.Errorf("want times to be shown in the saved time zone")
+	}
+}
+
+func TestExportEvent(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Event", "/event/1.ics", http.StatusOK, []byte("STATUS:TENTATIVE")},
+		{"Identifiers from the public URL", "/event/1.ics", http.StatusOK, []byte("UID:event-1-slot-1@doodle.example.com")},
+		{"Non-existent event", "/event/2.ics", http.StatusNotFound, nil},
+		{"Feed", "/feed/alice-feed-token.ics", http.StatusOK, []byte("SUMMARY:Music festival")},
+		{"Invalid feed token", "/feed/wrong.ics", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if code == http.StatusOK && !strings.HasPrefix(header.Get("Content-Type"), "text/calendar") {
+				t.Errorf("want a calendar; got %q", header.Get("Content-Type"))
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestImportSlots(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	ts.login(t, "alice@example.com", "")
+
+	_, _, body := ts.get(t, "/event/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	calendar := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"DTSTART:20300701T190000Z",
+		"DTEND:20300701T210000Z",
+		"END:VEVENT",
+		"BEGIN:VFREEBUSY",
+		"FREEBUSY;FBTYPE=FREE:20300702T080000Z/PT2H",
+		"END:VFREEBUSY",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	tests := []struct {
+		name     string
+		file     string
+		wantBody []byte
+	}{
+		{"Valid", calendar, []byte("value='2030-07-02T08:00'")},
+		{"Invalid", "not a calendar", []byte("not a valid calendar")},
+		{"Past only", strings.Replace(strings.Replace(calendar, "2030", "2000", -1), "FBTYPE=FREE", "FBTYPE=BUSY", 1), []byte("No upcoming slot")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			mw := multipart.NewWriter(buf)
+			mw.WriteField("csrf_token", csrfToken)
+			mw.WriteField("time_zone", "UTC")
+			mw.WriteField("import", "1")
+			fw, err := mw.CreateFormFile("ics", "slots.ics")
+			if err != nil {
+				t.Fatal(err)
+			}
+			fw.Write([]byte(tt.file))
+			mw.Close()
+
+			rs, err := ts.Client().Post(ts.URL+"/event/create", mw.FormDataContentType(), buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rs.Body.Close()
+
+			body, err := ioutil.ReadAll(rs.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if rs.StatusCode != http.StatusOK {
+				t.Errorf("want %d; got %d", http.StatusOK, rs.StatusCode)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestTokens(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "")
+
+	_, _, body := ts.get(t, "/user/tokens")
+	csrfToken := extractCSRFToken(t, body)
+
+	if !bytes.Contains(body, []byte("Deploy bot")) {
+		t.Errorf("want body to list the existing token")
+	}
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		form     url.Values
+		wantCode int
+		wantBody []byte
+	}{
+		{"Create", "/user/tokens", url.Values{"name": {"CI"}}, http.StatusOK, []byte("Copy it now")},
+		{"Create without name", "/user/tokens", url.Values{"name": {""}}, http.StatusOK, []byte("This field cannot be blank")},
+		{"Revoke", "/user/tokens/1/revoke", url.Values{}, http.StatusSeeOther, nil},
+		{"Revoke non-existent", "/user/tokens/2/revoke", url.Values{}, http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, tt.urlPath, tt.form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestForgotPassword(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/password/forgot")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		email    string
+		wantCode int
+		wantBody []byte
+		wantMail bool
+	}{
+		{"Registered", "alice@example.com", http.StatusSeeOther, nil, true},
+		{"Unknown", "nobody@example.com", http.StatusSeeOther, nil, false},
+		{"Invalid", "alice", http.StatusOK, []byte("This field is invalid"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(sentMails(app))
+
+			form := url.Values{}
+			form.Add("email", tt.email)
+			form.Add("csrf_token", csrfToken)
+
+			code, header, body := ts.postForm(t, "/user/password/forgot", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if code == http.StatusSeeOther && header.Get("Location") != "/user/login" {
+				t.Errorf("want redirection to the login page; got %q", header.Get("Location"))
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+
+			mails := sentMails(app)[before:]
+			if sent := len(mails) > 0; sent != tt.wantMail {
+				t.Fatalf("want mail sent %t; got %t", tt.wantMail, sent)
+			}
+			if tt.wantMail && mails[0].To != tt.email {
+				t.Errorf("want mail sent to %q; got %q", tt.email, mails[0].To)
+			}
+		})
+	}
+
+	t.Run("Forged host", func(t *testing.T) {
+		before := len(sentMails(app))
+
+		form := url.Values{}
+		form.Add("email", "bob@example.com")
+		form.Add("csrf_token", csrfToken)
+
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/user/password/forgot", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Host = "evil.example"
+		req.Header.Set("Referer", "https://evil.example/user/password/forgot")
+
+		// the cookies are looked up by the forged host otherwise
+		for _, c := range ts.Client().Jar.Cookies(req.URL) {
+			req.AddCookie(c)
+		}
+
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+
+		mails := sentMails(app)[before:]
+		if len(mails) != 1 {
+			t.Fatalf("want a mail to be sent; got %d", len(mails))
+		}
+		if link := mailLink(t, mails[0]); link.Host != "doodle.example.com" {
+			t.Errorf("want link to the configured URL; got %s", link)
+		}
+	})
+}
+
+func TestResetPassword(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		token    string
+		wantBody []byte
+	}{
+		{"Valid", "alice-reset-token", []byte("Change password")},
+		{"Invalid", "wrong", []byte("This link is invalid or has expired")},
+		{"Missing", "", []byte("This link is invalid or has expired")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, "/user/password/reset?token="+url.QueryEscape(tt.token))
+
+			if code != http.StatusOK {
+				t.Errorf("want %d; got %d", http.StatusOK, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+
+	_, _, body := ts.get(t, "/user/password/reset?token=alice-reset-token")
+	csrfToken := extractCSRFToken(t, body)
+
+	posts := []struct {
+		name     string
+		token    string
+		password string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Reset", "alice-reset-token", "new-pa$$word", http.StatusSeeOther, nil},
+		{"Short password", "alice-reset-token", "short", http.StatusOK, []byte("This field is too short")},
+		{"Invalid token", "wrong", "new-pa$$word", http.StatusOK, []byte("This link is invalid or has expired")},
+	}
+
+	for _, tt := range posts {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("token", tt.token)
+			form.Add("password", tt.password)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/user/password/reset", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestVerification(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Valid", "/user/verify?token=bob-verify-token", http.StatusSeeOther, nil},
+		{"Invalid", "/user/verify?token=wrong", http.StatusOK, []byte("This link is invalid or has expired")},
+		{"Missing", "/user/verify", http.StatusOK, []byte("This link is invalid or has expired")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Unverified cannot create events", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		ts.login(t, "bob@example.com", "")
+
+		code, header, _ := ts.get(t, "/event/create")
+		if code != http.StatusSeeOther || header.Get("Location") != "/user/verification" {
+			t.Errorf("want redirection to the verification page; got %d to %q", code, header.Get("Location"))
+		}
+
+		code, _, _ = ts.do(t, http.MethodPost, "/api/v1/events", `{}`)
+		if code != http.StatusForbidden {
+			t.Errorf("want %d from the API; got %d", http.StatusForbidden, code)
+		}
+
+		// a link has just been sent to Bob
+		_, _, body := ts.get(t, "/user/verification")
+
+		form := url.Values{}
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		before := len(sentMails(app))
+
+		code, _, _ = ts.postForm(t, "/user/verification", form)
+		if code != http.StatusSeeOther {
+			t.Errorf("want %d; got %d", http.StatusSeeOther, code)
+		}
+
+		_, _, body = ts.get(t, "/user/verification")
+		if !bytes.Contains(body, []byte("Please wait a few minutes")) {
+			t.Errorf("want resending to be throttled")
+		}
+		if len(sentMails(app)) != before {
+			t.Errorf("want no mail to be sent")
+		}
+	})
+
+	t.Run("Verified can create events", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		ts.login(t, "alice@example.com", "")
+
+		code, _, _ := ts.get(t, "/event/create")
+		if code != http.StatusOK {
+			t.Errorf("want %d; got %d", http.StatusOK, code)
+		}
+	})
+}
+
+func TestVerificationFlow(t *testing.T) {
+	app := newMemoryApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/signup")
+
+	form := url.Values{}
+	form.Add("name", "Alice")
+	form.Add("email", "alice@example.com")
+	form.Add("password", "validPa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/user/signup", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("want signup to succeed; got %d", code)
+	}
+
+	ts.login(t, "alice@example.com", "validPa$$word")
+
+	code, _, _ = ts.get(t, "/event/create")
+	if code != http.StatusSeeOther {
+		t.Fatalf("want event creation to require a confirmed address; got %d", code)
+	}
+
+	mails := sentMails(app)
+	if len(mails) != 1 || mails[0].To != "alice@example.com" {
+		t.Fatalf("want a confirmation mail to be sent to alice@example.com; got %d mails", len(mails))
+	}
+
+	code, _, _ = ts.get(t, mailLink(t, mails[0]).RequestURI())
+	if code != http.StatusSeeOther {
+		t.Fatalf("want address to be confirmed; got %d", code)
+	}
+
+	code, _, _ = ts.get(t, "/event/create")
+	if code != http.StatusOK {
+		t.Errorf("want event creation to be allowed; got %d", code)
+	}
+
+	_, _, body = ts.get(t, mailLink(t, mails[0]).RequestURI())
+	if !bytes.Contains(body, []byte("This link is invalid or has expired")) {
+		t.Errorf("want the link to be used up")
+	}
+}
+
+func TestPasswordResetFlow(t *testing.T) {
+	app := newMemoryApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.signup(t, app, "Alice", "alice@example.com", "validPa$$word")
+
+	_, _, body := ts.get(t, "/user/password/forgot")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("csrf_token", csrfToken)
+
+	before := len(sentMails(app))
+
+	code, _, _ := ts.postForm(t, "/user/password/forgot", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("want reset to be requested; got %d", code)
+	}
+
+	mails := sentMails(app)[before:]
+	if len(mails) != 1 {
+		t.Fatalf("want 1 mail; got %d", len(mails))
+	}
+	linkURL := mailLink(t, mails[0])
+
+	_, _, body = ts.get(t, linkURL.RequestURI())
+	if !bytes.Contains(body, []byte("Change password")) {
+		t.Fatalf("want the link to be valid")
+	}
+
+	form = url.Values{}
+	form.Add("token", linkURL.Query().Get("token"))
+	form.Add("password", "otherPa$$word")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, _ = ts.postForm(t, "/user/password/reset", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("want password to be changed; got %d", code)
+	}
+
+	ts.login(t, "alice@example.com", "otherPa$$word")
+
+	_, _, body = ts.get(t, linkURL.RequestURI())
+	if !bytes.Contains(body, []byte("This link is invalid or has expired")) {
+		t.Errorf("want the link to be used up")
+	}
+}
+
+func TestAccount(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "")
+
+	_, _, body := ts.get(t, "/user/account")
+	csrfToken := extractCSRFToken(t, body)
+
+	if !bytes.Contains(body, []byte("value='alice@example.com'")) {
+		t.Errorf("want the current email to be filled in")
+	}
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		form     url.Values
+		wantCode int
+		wantBody []byte
+	}{
+		{"Name", "/user/account/name", url.Values{"name": {"Alice Liddell"}}, http.StatusSeeOther, nil},
+		{"Blank name", "/user/account/name", url.Values{"name": {""}}, http.StatusOK, []byte("This field cannot be blank")},
+		{"Email", "/user/account/email", url.Values{"email": {"liddell@example.com"}}, http.StatusSeeOther, nil},
+		{"Email in use", "/user/account/email", url.Values{"email": {"bob@example.com"}}, http.StatusOK, []byte("Address is already in use")},
+		{"Invalid email", "/user/account/email", url.Values{"email": {"alice"}}, http.StatusOK, []byte("This field is invalid")},
+		{"Password", "/user/account/password", url.Values{"current_password": {"pa$$word123"}, "new_password": {"new-pa$$word"}}, http.StatusSeeOther, nil},
+		{"Wrong password", "/user/account/password", url.Values{"current_password": {"wrong-password"}, "new_password": {"new-pa$$word"}}, http.StatusOK, []byte("Password is incorrect")},
+		{"Short password", "/user/account/password", url.Values{"current_password": {"pa$$word123"}, "new_password": {"short"}}, http.StatusOK, []byte("This field is too short")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, tt.urlPath, tt.form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Without CSRF token", func(t *testing.T) {
+		code, _, _ := ts.postForm(t, "/user/account/name", url.Values{"name": {"Mallory"}})
+		if code != http.StatusBadRequest {
+			t.Errorf("want %d; got %d", http.StatusBadRequest, code)
+		}
+	})
+}
+
+func TestAccountFlow(t *testing.T) {
+	app := newMemoryApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.signup(t, app, "Alice", "alice@example.com", "validPa$$word")
+	ts.login(t, "alice@example.com", "validPa$$word")
+
+	_, _, body := ts.get(t, "/user/account")
+
+	form := url.Values{}
+	form.Add("email", "liddell@example.com")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	before := len(sentMails(app))
+
+	code, _, _ := ts.postForm(t, "/user/account/email", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("want email to be changed; got %d", code)
+	}
+
+	code, _, _ = ts.get(t, "/event/create")
+	if code != http.StatusSeeOther {
+		t.Errorf("want the new address to require a confirmation; got %d", code)
+	}
+
+	mails := sentMails(app)[before:]
+	if len(mails) != 1 || mails[0].To != "liddell@example.com" {
+		t.Fatalf("want a confirmation mail to be sent to the new address; got %d mails", len(mails))
+	}
+
+	code, _, _ = ts.get(t, mailLink(t, mails[0]).RequestURI())
+	if code != http.StatusSeeOther {
+		t.Fatalf("want new address to be confirmed; got %d", code)
+	}
+
+	code, _, _ = ts.get(t, "/event/create")
+	if code != http.StatusOK {
+		t.Errorf("want event creation to be allowed again; got %d", code)
+	}
+
+	ts.login(t, "liddell@example.com", "validPa$$word")
+}
+
+func TestPasswordChangeFlow(t *testing.T) {
+	app := newMemoryApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	other := newTestServer(t, app.routes())
+	defer other.Close()
+
+	ts.signup(t, app, "Alice", "alice@example.com", "validPa$$word")
+	ts.login(t, "alice@example.com", "validPa$$word")
+	other.login(t, "alice@example.com", "validPa$$word")
+
+	err := app.tokenStore.Insert(context.Background(), 1, "Script", "alice-api-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, body := ts.get(t, "/user/account")
+
+	form := url.Values{}
+	form.Add("current_password", "validPa$$word")
+	form.Add("new_password", "otherPa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/user/account/password", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("want password to be changed; got %d", code)
+	}
+
+	code, _, _ = ts.get(t, "/user/account")
+	if code != http.StatusOK {
+		t.Errorf("want the current session to be kept; got %d", code)
+	}
+
+	code, _, _ = other.get(t, "/user/account")
+	if code != http.StatusSeeOther {
+		t.Errorf("want the other session to be ended; got %d", code)
+	}
+
+	_, err = app.tokenStore.Authenticate(context.Background(), "alice-api-token")
+	if !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Errorf("want API tokens to be revoked; got %v", err)
+	}
+
+	other.login(t, "alice@example.com", "otherPa$$word")
+}
+
+func TestCloseAccount(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		form     url.Values
+		wantCode int
+		wantBody []byte
+	}{
+		{"Deactivate", "alice@example.com", "/user/account/deactivate", url.Values{"deactivate_password": {"pa$$word123"}}, http.StatusSeeOther, nil},
+		{"Deactivate with wrong password", "alice@example.com", "/user/account/deactivate", url.Values{"deactivate_password": {"wrong-password"}}, http.StatusOK, []byte("Password is incorrect")},
+		{"Deactivate without password", "alice@example.com", "/user/account/deactivate", url.Values{}, http.StatusOK, []byte("This field cannot be blank")},
+		{"Erase", "alice@example.com", "/user/account/erase", url.Values{"erase_password": {"pa$$word123"}}, http.StatusSeeOther, nil},
+		{"Erase with wrong password", "alice@example.com", "/user/account/erase", url.Values{"erase_password": {"wrong-password"}}, http.StatusOK, []byte("Password is incorrect")},
+		{"Deactivate last administrator", "carol@example.com", "/user/account/deactivate", url.Values{"deactivate_password": {"pa$$word123"}}, http.StatusOK, []byte("You are the last active administrator")},
+		{"Erase last administrator", "carol@example.com", "/user/account/erase", url.Values{"erase_password": {"pa$$word123"}}, http.StatusOK, []byte("You are the last active administrator")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, tt.email, "")
+
+			_, _, body := ts.get(t, "/user/account")
+			tt.form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, body := ts.postForm(t, tt.urlPath, tt.form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+
+			// the session is closed along with the account
+			code, _, _ = ts.get(t, "/user/account")
+			if loggedOut := code == http.StatusSeeOther; loggedOut != (tt.wantCode == http.StatusSeeOther) {
+				t.Errorf("want logged out %t; got %t", tt.wantCode == http.StatusSeeOther, loggedOut)
+			}
+		})
+	}
+}
+
+func TestExportAccount(t *testing.T) {
+	app := newMemoryApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, _ := ts.get(t, "/user/account/export")
+	if code != http.StatusSeeOther {
+		t.Errorf("want anonymous users to be redirected; got %d", code)
+	}
+
+	ts.signup(t, app, "Alice", "alice@example.com", "validPa$$word")
+	ts.login(t, "alice@example.com", "validPa$$word")
+
+	ctx := context.Background()
+	when := time.Now().Add(24 * time.Hour)
+	slots := []*models.Slot{{Start: when, End: when.Add(time.Hour)}}
+
+	own, err := app.eventStore.Insert(ctx, 1, "Band rehearsal", "", when, "", slots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := app.eventStore.Insert(ctx, 0, "Concert", "", when, "", slots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evt, err := app.eventStore.Get(ctx, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	answers := map[int]models.Answer{evt.Slots[0].ID: models.AnswerYes}
+	if err = app.voteStore.Upsert(ctx, other, 1, "Alice", answers); err != nil {
+		t.Fatal(err)
+	}
+	if err = app.voteStore.Upsert(ctx, other, 0, "Carol", answers); err != nil {
+		t.Fatal(err)
+	}
+
+	code, header, body := ts.get(t, "/user/account/export")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if header.Get("Content-Type") != "application/zip" {
+		t.Errorf("want a ZIP archive; got %q", header.Get("Content-Type"))
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name], err = ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var profile exportProfile
+	if err = json.Unmarshal(files["profile.json"], &profile); err != nil {
+		t.Fatal(err)
+	}
+	if profile.Email != "alice@example.com" || !profile.Verified {
+		t.Errorf("unexpected profile %+v", profile)
+	}
+
+	var events []*apiEvent
+	if err = json.Unmarshal(files["events.json"], &events); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].ID != own {
+		t.Errorf("want the created event only; got %+v", events)
+	}
+
+	var votes []*exportVote
+	if err = json.Unmarshal(files["votes.json"], &votes); err != nil {
+		t.Fatal(err)
+	}
+	if len(votes) != 1 || votes[0].Event.ID != other || votes[0].Vote.Name != "Alice" {
+		t.Fatalf("want the vote of the user only; got %+v", votes)
+	}
+	if votes[0].Event.Participants != nil || votes[0].Event.Slots[0].Yes != 2 {
+		t.Errorf("want tallies without the other participants; got %+v", votes[0].Event)
+	}
+
+	if _, ok := files["tokens.json"]; !ok {
+		t.Errorf("want tokens to be exported")
+	}
+}
+
+func TestEraseAccountFlow(t *testing.T) {
+	app := newMemoryApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.signup(t, app, "Alice", "alice@example.com", "validPa$$word")
+	ts.login(t, "alice@example.com", "validPa$$word")
+
+	ctx := context.Background()
+	when := time.Now().Add(24 * time.Hour)
+	slots := []*models.Slot{{Start: when, End: when.Add(time.Hour)}}
+
+	other, err := app.eventStore.Insert(ctx, 0, "Concert", "", when, "", slots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evt, err := app.eventStore.Get(ctx, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = app.voteStore.Upsert(ctx, other, 1, "Alice", map[int]models.Answer{evt.Slots[0].ID: models.AnswerYes}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, body := ts.get(t, "/user/account")
+
+	form := url.Values{}
+	form.Add("erase_password", "validPa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/user/account/erase", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("want account to be erased; got %d", code)
+	}
+
+	_, _, body = ts.get(t, fmt.Sprintf("/event/%d", other))
+	if bytes.Contains(body, []byte("Alice")) {
+		t.Errorf("want the name of the user to be removed from the polls")
+	}
+	if !bytes.Contains(body, []byte(models.ErasedName)) {
+		t.Errorf("want the vote to be kept anonymously")
+	}
+
+	// the address can be used again
+	ts.signup(t, app, "Alice", "alice@example.com", "validPa$$word")
+}
+
+func TestEventFlow(t *testing.T) {
+	app := newMemoryApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.signup(t, app, "Alice", "alice@example.com", "validPa$$word")
+	ts.login(t, "alice@example.com", "validPa$$word")
+
+	_, _, body := ts.get(t, "/event/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	future := time.Now().Add(72 * time.Hour)
+
+	form := url.Values{}
+	form.Add("title", "Band rehearsal")
+	form.Add("desc", "Bring your instrument")
+	form.Add("time", future.Format(forms.DateTimeLayout))
+	form.Add("slot_start", future.Add(-2*time.Hour).Format(forms.DateTimeLayout))
+	form.Add("slot_end", future.Add(-time.Hour).Format(forms.DateTimeLayout))
+	form.Add("csrf_token", csrfToken)
+
+	code, header, _ := ts.postForm(t, "/event/create", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("want event to be created; got %d", code)
+	}
+	eventURL := header.Get("Location")
+
+	_, _, body = ts.get(t, eventURL)
+	if !bytes.Contains(body, []byte("Bring your instrument")) {
+		t.Errorf("want created event to be shown")
+	}
+
+	form = url.Values{}
+	form.Add("name", "Carol")
+	form.Add("slot_1", "yes")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, _ = ts.postForm(t, eventURL+"/vote", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("want vote to be saved; got %d", code)
+	}
+
+	_, _, body = ts.get(t, eventURL)
+	if !bytes.Contains(body, []byte("Carol")) {
+		t.Errorf("want participant to be shown")
+	}
+
+	_, _, body = ts.get(t, "/")
+	if !bytes.Contains(body, []byte("Band rehearsal")) {
+		t.Errorf("want event to be listed on the home page")
+	}
+
+	_, _, body = ts.get(t, "/events?owner=me")
+	if !bytes.Contains(body, []byte("Band rehearsal")) {
+		t.Errorf("want event to be listed among the events of its owner")
+	}
+
+	_, _, body = ts.get(t, "/search?q=instrument")
+	if !bytes.Contains(body, []byte("Bring your <mark>instrument</mark>")) {
+		t.Errorf("want event to be found by searching its description")
+	}
+}
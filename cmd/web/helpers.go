@@ -2,21 +2,41 @@ package main
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"runtime/debug"
+	"strconv"
 	"time"
 
 	"github.com/justinas/nosurf"
+	"github.com/lobre/doodle/pkg/forms"
+	"github.com/lobre/doodle/pkg/ical"
+	"github.com/lobre/doodle/pkg/mailer"
+	"github.com/lobre/doodle/pkg/models"
 )
 
 // The serverError helper writes an error message and stack trace to the errorLog,
-// then sends a generic 500 Internal Server Error response to the user.
+// then sends a generic 500 Internal Server Error response to the user. When the
+// database took too long to answer, a 503 Service Unavailable is sent instead,
+// as trying again later may succeed.
 func (app *application) serverError(w http.ResponseWriter, err error) {
 	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
 	app.errorLog.Output(2, trace)
 
-	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	status := serverErrorStatus(err)
+	http.Error(w, http.StatusText(status), status)
+}
+
+// serverErrorStatus returns the status code to send for an unexpected error.
+func serverErrorStatus(err error) int {
+	if errors.Is(err, models.ErrTimeout) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
 }
 
 // The clientError helper sends a specific status code and corresponding description
@@ -32,15 +52,163 @@ func (app *application) notFound(w http.ResponseWriter) {
 	app.clientError(w, http.StatusNotFound)
 }
 
+// The forbidden helper sends a 403 Forbidden response, to be used when the
+// user is authenticated but is not allowed to act on a resource.
+func (app *application) forbidden(w http.ResponseWriter) {
+	app.clientError(w, http.StatusForbidden)
+}
+
+// The event helper fetches the event whose ID is given in the URL. If it
+// cannot be retrieved, or is hidden from the current user, the appropriate
+// error is sent and nil is returned.
+func (app *application) event(w http.ResponseWriter, r *http.Request) *models.Event {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil
+	}
+
+	evt, err := app.eventStore.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil
+	}
+
+	// hidden events only exist for administrators
+	if evt.Hidden && !app.isAdmin(r) {
+		app.notFound(w)
+		return nil
+	}
+
+	return evt
+}
+
+// The ownedEvent helper works as the event helper, but also sends a forbidden
+// error if the current user is not the owner of the event.
+func (app *application) ownedEvent(w http.ResponseWriter, r *http.Request) *models.Event {
+	evt := app.event(w, r)
+	if evt == nil {
+		return nil
+	}
+
+	if !app.isOwner(r, evt) {
+		app.forbidden(w)
+		return nil
+	}
+
+	return evt
+}
+
+// The eventFilter helper validates the query string of an event listing,
+// held by the form, and turns it into a filter. Dates are interpreted in the
+// location of the form, and the range includes the end date. If the query is
+// invalid, false is returned and the form holds the errors.
+func (app *application) eventFilter(r *http.Request, form *forms.Form) (models.EventFilter, bool) {
+	form.ValidDate("from", "to")
+	form.PermittedValues("sort", "asc", "desc")
+	form.PermittedValues("owner", "me")
+
+	f := models.EventFilter{
+		From: form.GetDate("from"),
+		Desc: form.Get("sort") == "desc",
+	}
+
+	if to := form.GetDate("to"); !to.IsZero() {
+		f.To = to.AddDate(0, 0, 1)
+		if !f.From.IsZero() && to.Before(f.From) {
+			form.Errors.Add("to", "This field cannot be before the start date")
+		}
+	}
+
+	if form.Get("owner") == "me" {
+		f.UserID = app.authenticatedUserID(r)
+		if f.UserID == 0 {
+			form.Errors.Add("owner", "You must be logged in to see your events")
+		}
+	}
+
+	if after := form.Get("after"); after != "" {
+		cursor, err := models.ParseCursor(after)
+		if err != nil {
+			form.Errors.Add("after", "This page does not exist")
+		}
+		f.After = cursor
+	}
+
+	return f, form.Valid()
+}
+
+// The editableEvent helper works as the ownedEvent helper, but also refuses
+// to change events that have already happened, as they are kept read-only.
+// The user is then sent back to the page of the event with a message.
+func (app *application) editableEvent(w http.ResponseWriter, r *http.Request) *models.Event {
+	evt := app.ownedEvent(w, r)
+	if evt == nil {
+		return nil
+	}
+
+	if evt.Past() {
+		app.session.Put(r, "flash", "This event has already happened.")
+		http.Redirect(w, r, fmt.Sprintf("/event/%d", evt.ID), http.StatusSeeOther)
+		return nil
+	}
+
+	return evt
+}
+
+// The renderEvent helper renders the page of an event along with its poll.
+// The form is used for the vote of the current participant. When it contains
+// an "edit" field, it is prefilled with the answers of that participant.
+func (app *application) renderEvent(w http.ResponseWriter, r *http.Request, evt *models.Event, form *forms.Form) {
+	participants, err := app.voteStore.ForEvent(r.Context(), evt.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if edit, err := strconv.Atoi(form.Get("edit")); err == nil {
+		form.Del("edit")
+		for _, p := range participants {
+			if p.ID != edit {
+				continue
+			}
+			form.Set("name", p.Name)
+			for slotID, answer := range p.Answers {
+				form.Set(fmt.Sprintf("slot_%d", slotID), string(answer))
+			}
+		}
+	}
+
+	tallies := models.Tallies(participants)
+
+	app.render(w, r, "show.page.tmpl", &templateData{
+		Event:        evt,
+		Form:         form,
+		IsOwner:      app.isOwner(r, evt),
+		BestSlot:     models.BestSlot(evt.Slots, tallies),
+		Participants: participants,
+		Tallies:      tallies,
+	})
+}
+
 // The addDefaultData helper will automatically inject data that are common to all pages.
 func (app *application) addDefaultData(td *templateData, r *http.Request) *templateData {
 	if td == nil {
 		td = &templateData{}
 	}
+	td.BaseURL = app.publicURL
 	td.CSRFToken = nosurf.Token(r)
 	td.CurrentYear = time.Now().Year()
 	td.Flash = app.session.PopString(r, "flash")
 	td.IsAuthenticated = app.isAuthenticated(r)
+	td.IsAdmin = app.isAdmin(r)
+	td.Location = app.location(r)
+	// reloading a page answering a form would submit it again
+	td.Reloadable = r.Method == http.MethodGet
 	return td
 }
 
@@ -76,3 +244,278 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 	}
 	return isAuthenticated
 }
+
+// startSession logs the user in for the session of the request. The session
+// keeps the session version of the user, so that it ends when the password
+// changes.
+func (app *application) startSession(r *http.Request, id int) error {
+	user, err := app.userStore.Get(r.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	app.session.Put(r, "authenticatedUserID", user.ID)
+	app.session.Put(r, "sessionVersion", user.SessionVersion)
+	return nil
+}
+
+// endSession logs the user out of the session of the request.
+func (app *application) endSession(r *http.Request) {
+	app.session.Remove(r, "authenticatedUserID")
+	app.session.Remove(r, "sessionVersion")
+}
+
+// authenticatedUserID returns the ID of the current user, or 0 if the
+// request is not authenticated.
+func (app *application) authenticatedUserID(r *http.Request) int {
+	user := app.authenticatedUser(r)
+	if user == nil {
+		return 0
+	}
+	return user.ID
+}
+
+// isOwner returns true if the current user has created the given event.
+func (app *application) isOwner(r *http.Request, evt *models.Event) bool {
+	id := app.authenticatedUserID(r)
+	return id != 0 && id == evt.UserID
+}
+
+// isAdmin returns true if the current user is an administrator.
+func (app *application) isAdmin(r *http.Request) bool {
+	user := app.authenticatedUser(r)
+	return user != nil && user.IsAdmin()
+}
+
+// authenticatedUser returns the current user, or nil if the request
+// is not authenticated.
+func (app *application) authenticatedUser(r *http.Request) *models.User {
+	user, ok := r.Context().Value(contextKeyUser).(*models.User)
+	if !ok {
+		return nil
+	}
+	return user
+}
+
+// location returns the time zone of the viewer. The preference of the user
+// is used first, then the zone detected by the browser and stored in the tz
+// cookie. It falls back to UTC.
+func (app *application) location(r *http.Request) *time.Location {
+	if user := app.authenticatedUser(r); user != nil && user.TimeZone != "" {
+		if loc, err := time.LoadLocation(user.TimeZone); err == nil {
+			return loc
+		}
+	}
+
+	if c, err := r.Cookie("tz"); err == nil {
+		name, err := url.QueryUnescape(c.Value)
+		if err == nil && name != "" && name != "Local" {
+			if loc, err := time.LoadLocation(name); err == nil {
+				return loc
+			}
+		}
+	}
+
+	return time.UTC
+}
+
+// generateToken returns a random URL-safe token, suitable for secrets
+// sent to users in links.
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// sendVerification emails a link to confirm the address of the user
+// with the given email.
+func (app *application) sendVerification(r *http.Request, email string) error {
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	err = app.verifStore.Insert(r.Context(), email, token, time.Now().Add(verifyTokenLifetime))
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/user/verify?token=%s", app.publicURL, url.QueryEscape(token))
+	app.sendMail(&mailer.Message{
+		To:      email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Welcome to Doodle!\n\n"+
+			"To confirm your email address and start creating events, follow this link within two days:\n\n%s\n\n"+
+			"If you did not sign up, you can ignore this email.\n", link),
+	})
+
+	return nil
+}
+
+// sendPasswordReset emails a link to reset the password of the active user
+// with the given email, after the given introduction. It returns
+// models.ErrNoRecord if there is no such user.
+func (app *application) sendPasswordReset(r *http.Request, email, intro string) error {
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	err = app.resetStore.Insert(r.Context(), email, token, time.Now().Add(resetTokenLifetime))
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/user/password/reset?token=%s", app.publicURL, url.QueryEscape(token))
+	app.sendMail(&mailer.Message{
+		To:      email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("%s\n\n"+
+			"To choose a new password, follow this link within the next hour:\n\n%s\n\n"+
+			"If you did not ask for it, you can ignore this email.\n", intro, link),
+	})
+
+	return nil
+}
+
+// audit records an action of the current administrator in the audit trail.
+// It is called once the action has been carried out, so that the trail only
+// holds actions that happened. If it fails, a server error is sent and false
+// is returned.
+func (app *application) audit(w http.ResponseWriter, r *http.Request, action models.Action, targetID int) bool {
+	err := app.auditStore.Insert(r.Context(), app.authenticatedUserID(r), action, targetID)
+	if err != nil {
+		app.serverError(w, err)
+		return false
+	}
+	return true
+}
+
+// icalEvents converts an event to calendar entries. A closed event gives a
+// single confirmed entry for its final slot, otherwise each candidate slot
+// gives a tentative entry, or a cancelled one if the event has been cancelled.
+// The identifiers of the entries use the host of the public URL, as calendar
+// applications would duplicate them if they changed with the requested host.
+func (app *application) icalEvents(evt *models.Event) []*ical.Event {
+	link := fmt.Sprintf("%s/event/%d", app.publicURL, evt.ID)
+
+	domain := app.publicURL
+	if u, err := url.Parse(app.publicURL); err == nil {
+		domain = u.Hostname()
+	}
+
+	entry := func(s *models.Slot, status string) *ical.Event {
+		return &ical.Event{
+			UID:         fmt.Sprintf("event-%d-slot-%d@%s", evt.ID, s.ID, domain),
+			Start:       s.Start,
+			End:         s.End,
+			Summary:     evt.Title,
+			Description: evt.Desc,
+			URL:         link,
+			Status:      status,
+		}
+	}
+
+	if final := evt.FinalSlot(); evt.Status == models.StatusClosed && final != nil {
+		return []*ical.Event{entry(final, ical.StatusConfirmed)}
+	}
+
+	status := ical.StatusTentative
+	if evt.Status == models.StatusCancelled {
+		status = ical.StatusCancelled
+	}
+
+	entries := []*ical.Event{}
+	for _, s := range evt.Slots {
+		entries = append(entries, entry(s, status))
+	}
+	return entries
+}
+
+// The writeCalendar helper sends a calendar as an iCalendar file. A filename
+// makes browsers download it instead of handing it to a calendar application.
+func (app *application) writeCalendar(w http.ResponseWriter, cal *ical.Calendar, filename string) {
+	buf := new(bytes.Buffer)
+
+	err := ical.NewEncoder(buf).Encode(cal)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	if filename != "" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	}
+
+	buf.WriteTo(w)
+}
+
+// renderTokens renders the page listing the API tokens of the current user.
+// The newly created token, if any, is shown along.
+func (app *application) renderTokens(w http.ResponseWriter, r *http.Request, form *forms.Form, newToken string) {
+	tokens, err := app.tokenStore.ForUser(r.Context(), app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "tokens.page.tmpl", &templateData{
+		Form:     form,
+		Tokens:   tokens,
+		NewToken: newToken,
+	})
+}
+
+// renderAccount renders the account page of the current user. The fields
+// that have not been submitted with the form show the current values.
+func (app *application) renderAccount(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	user := app.authenticatedUser(r)
+
+	if _, ok := form.Values["name"]; !ok {
+		form.Set("name", user.Name)
+	}
+	if _, ok := form.Values["email"]; !ok {
+		form.Set("email", user.Email)
+	}
+
+	app.render(w, r, "account.page.tmpl", &templateData{
+		Form: form,
+		User: user,
+	})
+}
+
+// confirmPassword parses the posted form and checks that the given field
+// holds the password of the current user. The form is returned with an
+// error on the field if not. It returns false if a response has already
+// been sent.
+func (app *application) confirmPassword(w http.ResponseWriter, r *http.Request, field string) (*forms.Form, bool) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return nil, false
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required(field)
+
+	if !form.Valid() {
+		return form, true
+	}
+
+	user := app.authenticatedUser(r)
+
+	id, err := app.userStore.Authenticate(r.Context(), user.Email, form.Get(field))
+	if err != nil && !errors.Is(err, models.ErrInvalidCredentials) {
+		app.serverError(w, err)
+		return nil, false
+	}
+
+	if err != nil || id != user.ID {
+		form.Errors.Add(field, "Password is incorrect")
+	}
+
+	return form, true
+}
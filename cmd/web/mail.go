@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lobre/doodle/pkg/mailer"
+)
+
+// mailTimeout bounds the time spent sending a single email.
+const mailTimeout = 30 * time.Second
+
+// mailerConfig holds the flags configuring how emails are sent.
+type mailerConfig struct {
+	kind     string
+	from     string
+	dir      string
+	smtpAddr string
+	smtpUser string
+	smtpPass string
+}
+
+// newMailer returns the mailer matching the configuration.
+func (app *application) newMailer(cfg mailerConfig) (mailer.Mailer, error) {
+	switch cfg.kind {
+	case "log":
+		return &mailer.Log{Logger: app.infoLog, From: cfg.from}, nil
+	case "dir":
+		return &mailer.Dir{Path: cfg.dir, From: cfg.from}, nil
+	case "smtp":
+		return &mailer.SMTP{
+			Addr:     cfg.smtpAddr,
+			Username: cfg.smtpUser,
+			Password: cfg.smtpPass,
+			From:     cfg.from,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %q", cfg.kind)
+	}
+}
+
+// sendMail sends a message in the background, so that a slow mail server
+// neither delays the response nor reveals through timing whether an email
+// has been sent at all. Errors are only logged.
+func (app *application) sendMail(msg *mailer.Message) {
+	app.mails.Add(1)
+
+	go func() {
+		defer app.mails.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+
+		if err := app.mailer.Send(ctx, msg); err != nil {
+			app.errorLog.Printf("mail to %s: %s", msg.To, err)
+		}
+	}()
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestPublicURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		addr    string
+		https   bool
+		mailer  string
+		wantURL string
+		wantErr bool
+	}{
+		{"Configured", "https://doodle.example.com/", ":4000", false, "smtp", "https://doodle.example.com", false},
+		{"Required to send emails", "", ":4000", false, "smtp", "", true},
+		{"Required to write emails", "", ":4000", false, "dir", "", true},
+		{"Invalid", "doodle.example.com", ":4000", false, "smtp", "", true},
+		{"Local", "", ":4000", false, "log", "http://localhost:4000", false},
+		{"Local with TLS", "", "0.0.0.0:4443", true, "log", "https://localhost:4443", false},
+		{"Local address", "", "127.0.0.1:4000", false, "log", "http://127.0.0.1:4000", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := publicURL(tt.base, tt.addr, tt.https, tt.mailer)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error %t; got %v", tt.wantErr, err)
+			}
+
+			if u != tt.wantURL {
+				t.Errorf("want %q; got %q", tt.wantURL, u)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/justinas/nosurf"
 	"github.com/lobre/doodle/pkg/models"
@@ -63,10 +64,11 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		user, err := app.userStore.Get(app.session.GetInt(r, "authenticatedUserID"))
-		if errors.Is(err, models.ErrNoRecord) || !user.Active {
-			// session exists but user has been removed or disabled from db
-			app.session.Remove(r, "authenticatedUserID")
+		user, err := app.userStore.Get(r.Context(), app.session.GetInt(r, "authenticatedUserID"))
+		if errors.Is(err, models.ErrNoRecord) || (err == nil && (!user.Active || user.SessionVersion != app.session.GetInt(r, "sessionVersion"))) {
+			// session exists but user has been removed or disabled from db,
+			// or has changed their password since
+			app.endSession(r)
 			next.ServeHTTP(w, r)
 			return
 		} else if err != nil {
@@ -75,6 +77,51 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		}
 
 		ctx := context.WithValue(r.Context(), contextKeyIsAuthenticated, true)
+		ctx = context.WithValue(ctx, contextKeyUser, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticateToken authenticates the request from a personal API token given
+// in the Authorization header, in place of the session cookie. A request
+// carrying an invalid token is rejected rather than treated as anonymous, so
+// that scripts notice a revoked token.
+func (app *application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("WWW-Authenticate", "Bearer")
+
+		parts := strings.SplitN(header, " ", 2)
+		if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+			app.apiError(w, http.StatusUnauthorized, "Invalid authorization header")
+			return
+		}
+
+		userID, err := app.tokenStore.Authenticate(r.Context(), strings.TrimSpace(parts[1]))
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			app.apiError(w, http.StatusUnauthorized, "Invalid token")
+			return
+		} else if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+
+		user, err := app.userStore.Get(r.Context(), userID)
+		if errors.Is(err, models.ErrNoRecord) || (err == nil && !user.Active) {
+			app.apiError(w, http.StatusUnauthorized, "Invalid token")
+			return
+		} else if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeyIsAuthenticated, true)
+		ctx = context.WithValue(ctx, contextKeyUser, user)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -95,6 +142,44 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// requireVerification redirects authenticated users who have not confirmed
+// their email address yet to the page letting them do so. It must come
+// after requireAuthentication.
+func (app *application) requireVerification(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.authenticatedUser(r).Verified {
+			app.session.Put(r, "flash", "Please confirm your email address first.")
+			http.Redirect(w, r, "/user/verification", http.StatusSeeOther)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAdmin refuses access to users who are not administrators. It must
+// come after requireAuthentication.
+func (app *application) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAdmin(r) {
+			app.forbidden(w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitUploadSize prevents clients from sending bodies larger than what we accept
+// for uploaded files. It must come before any middleware parsing the form.
+func limitUploadSize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 // injectCSRFCookie will injects a customized CSRF token in a cookie (which is encrypted). That same token
 // will be used as a hidden field in forms (from nosurf.Token()). On the form submission, the server
 // will check that these two values match. It makes it impossible for an attacker
@@ -116,5 +201,14 @@ func (app *application) injectCSRFCookie(next http.Handler) http.Handler {
 	}
 
 	csrfHandler.SetBaseCookie(cookie)
+
+	// Requests authenticated by a token do not rely on cookies, so they cannot
+	// be forged by a third party website. Browsers won't send this header across
+	// sites without the consent of the server, and authenticateToken rejects it
+	// when it does not hold a valid token.
+	csrfHandler.ExemptFunc(func(r *http.Request) bool {
+		return r.Header.Get("Authorization") != ""
+	})
+
 	return csrfHandler
 }
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestAuthenticate(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		email    string
+		wantCode int
+	}{
+		{"Active user", "alice@example.com", http.StatusOK},
+		{"Database timeout", "slow@example.com", http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			// the user is read at login already to start the session
+			_, _, body := ts.get(t, "/user/login")
+
+			form := url.Values{}
+			form.Add("email", tt.email)
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, _ := ts.postForm(t, "/user/login", form)
+			if code == http.StatusSeeOther {
+				code, _, _ = ts.get(t, "/")
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/lobre/doodle/pkg/migrate"
+)
+
+// usage prints the help of the command, including its subcommands.
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] [migrate up|down|status | promote EMAIL]\n\n", os.Args[0])
+	fmt.Fprintf(out, "Without command, the web server is started.\n\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+// runMigrate runs the migrate subcommand given in args.
+func runMigrate(m *migrate.Migrator, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: migrate up|down|status")
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up()
+		for _, mig := range applied {
+			fmt.Fprintf(out, "Applied %d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(out, "The schema is up to date")
+		}
+		return nil
+
+	case "down":
+		mig, err := m.Down()
+		if errors.Is(err, migrate.ErrNoChange) {
+			fmt.Fprintln(out, "No migration to revert")
+			return nil
+		} else if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Reverted %d_%s\n", mig.Version, mig.Name)
+		return nil
+
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, s := range statuses {
+			applied := "pending"
+			if !s.Applied.IsZero() {
+				applied = s.Applied.UTC().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return w.Flush()
+
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
+// checkSchema refuses to start the server on a schema that is behind the
+// code, unless autoMigrate is set, in which case it brings it up to date.
+func checkSchema(m *migrate.Migrator, autoMigrate bool, infoLog *log.Logger) error {
+	pending, err := m.Pending()
+	if err != nil {
+		return err
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	if !autoMigrate {
+		return fmt.Errorf("the database schema is %d migration(s) behind, run the migrate up command or start with -auto-migrate", len(pending))
+	}
+
+	applied, err := m.Up()
+	for _, mig := range applied {
+		infoLog.Printf("Applied migration %d_%s", mig.Version, mig.Name)
+	}
+	return err
+}
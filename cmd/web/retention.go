@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// retainEvents deletes the events that happened more than the retention
+// period ago, once at start and then every interval, until ctx is done.
+func (app *application) retainEvents(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		app.purgeEvents(ctx, time.Now().Add(-retention))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeEvents deletes the events that happened before the given time.
+// Errors are only logged, as the next run will try again.
+func (app *application) purgeEvents(ctx context.Context, before time.Time) {
+	n, err := app.eventStore.Purge(ctx, before)
+	if err != nil {
+		app.errorLog.Printf("purge of past events: %s", err)
+		return
+	}
+
+	if n > 0 {
+		app.infoLog.Printf("Deleted %d events past the retention period", n)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lobre/doodle/pkg/models"
+)
+
+func TestPurgeEvents(t *testing.T) {
+	app := newMemoryApplication(t)
+	ctx := context.Background()
+
+	insert := func(when time.Time) int {
+		slots := []*models.Slot{{Start: when, End: when.Add(time.Hour)}}
+		id, err := app.eventStore.Insert(ctx, 0, "Rehearsal", "Bring your instrument", when, "", slots)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	old := insert(time.Now().Add(-60 * 24 * time.Hour))
+	recent := insert(time.Now().Add(-time.Hour))
+
+	app.purgeEvents(ctx, time.Now().Add(-30*24*time.Hour))
+
+	if _, err := app.eventStore.Get(ctx, old); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want event past the retention period to be deleted; got %v", err)
+	}
+	if _, err := app.eventStore.Get(ctx, recent); err != nil {
+		t.Errorf("want recent event to be kept; got %v", err)
+	}
+}
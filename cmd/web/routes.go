@@ -13,7 +13,7 @@ func (app *application) routes() http.Handler {
 	standardMiddleware := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
 
 	// This chain is used for all routes that are not static (css, js, ...).
-	dynamicMiddleware := alice.New(app.session.Enable, app.injectCSRFCookie, app.authenticate)
+	dynamicMiddleware := alice.New(app.session.Enable, app.injectCSRFCookie, app.authenticate, app.authenticateToken)
 
 	mux := pat.New()
 
@@ -24,9 +24,20 @@ func (app *application) routes() http.Handler {
 	mux.Get("/static/", http.StripPrefix("/static", fileServer))
 
 	// Events
-	mux.Get("/event/create", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.createEventForm))
-	mux.Post("/event/create", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.createEvent))
+	mux.Get("/events", dynamicMiddleware.ThenFunc(app.listEvents))
+	mux.Get("/search", dynamicMiddleware.ThenFunc(app.searchEvents))
+	mux.Get("/archive", dynamicMiddleware.ThenFunc(app.archive))
+	mux.Get("/event/create", dynamicMiddleware.Append(app.requireAuthentication, app.requireVerification).ThenFunc(app.createEventForm))
+	mux.Post("/event/create", alice.New(limitUploadSize).Extend(dynamicMiddleware).Append(app.requireAuthentication, app.requireVerification).ThenFunc(app.createEvent))
+	mux.Get("/event/:id.ics", dynamicMiddleware.ThenFunc(app.exportEvent))
 	mux.Get("/event/:id", dynamicMiddleware.ThenFunc(app.showEvent))
+	mux.Post("/event/:id/vote", dynamicMiddleware.ThenFunc(app.voteEvent))
+	mux.Get("/event/:id/edit", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.editEventForm))
+	mux.Post("/event/:id/edit", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.editEvent))
+	mux.Post("/event/:id/delete", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.deleteEvent))
+	mux.Post("/event/:id/close", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.closeEvent))
+	mux.Post("/event/:id/cancel", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.cancelEvent))
+	mux.Post("/event/:id/reopen", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.reopenEvent))
 
 	// Users
 	mux.Get("/user/signup", dynamicMiddleware.ThenFunc(app.signupUserForm))
@@ -34,6 +45,62 @@ func (app *application) routes() http.Handler {
 	mux.Get("/user/login", dynamicMiddleware.ThenFunc(app.loginUserForm))
 	mux.Post("/user/login", dynamicMiddleware.ThenFunc(app.loginUser))
 	mux.Post("/user/logout", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.logoutUser))
+	mux.Get("/user/password/forgot", dynamicMiddleware.ThenFunc(app.forgotPasswordForm))
+	mux.Post("/user/password/forgot", dynamicMiddleware.ThenFunc(app.forgotPassword))
+	mux.Get("/user/password/reset", dynamicMiddleware.ThenFunc(app.resetPasswordForm))
+	mux.Post("/user/password/reset", dynamicMiddleware.ThenFunc(app.resetPassword))
+	mux.Get("/user/verify", dynamicMiddleware.ThenFunc(app.verifyEmail))
+	mux.Get("/user/verification", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.verificationPage))
+	mux.Post("/user/verification", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.resendVerification))
+
+	// Account
+	mux.Get("/user/account", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.accountPage))
+	mux.Post("/user/account/name", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.changeName))
+	mux.Post("/user/account/email", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.changeEmail))
+	mux.Post("/user/account/password", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.changePassword))
+	mux.Get("/user/account/export", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.exportAccount))
+	mux.Post("/user/account/deactivate", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.deactivateAccount))
+	mux.Post("/user/account/erase", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.eraseAccount))
+
+	// API tokens
+	mux.Get("/user/tokens", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.tokensPage))
+	mux.Post("/user/tokens", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.createToken))
+	mux.Post("/user/tokens/:id/revoke", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.revokeToken))
+
+	// Calendar feeds
+	mux.Get("/user/calendar", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.calendarPage))
+	mux.Post("/user/calendar", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.resetFeedToken))
+	mux.Get("/feed/:token.ics", http.HandlerFunc(app.calendarFeed))
+
+	// Preferences
+	mux.Get("/timezone", dynamicMiddleware.ThenFunc(app.timeZoneForm))
+	mux.Post("/timezone", dynamicMiddleware.ThenFunc(app.setTimeZone))
+
+	// Administration
+	adminMiddleware := dynamicMiddleware.Append(app.requireAuthentication, app.requireAdmin)
+
+	mux.Get("/admin", adminMiddleware.ThenFunc(app.adminHome))
+	mux.Get("/admin/users", adminMiddleware.ThenFunc(app.adminUsers))
+	mux.Post("/admin/users/:id/activate", adminMiddleware.ThenFunc(app.adminActivateUser))
+	mux.Post("/admin/users/:id/deactivate", adminMiddleware.ThenFunc(app.adminDeactivateUser))
+	mux.Post("/admin/users/:id/promote", adminMiddleware.ThenFunc(app.adminPromoteUser))
+	mux.Post("/admin/users/:id/demote", adminMiddleware.ThenFunc(app.adminDemoteUser))
+	mux.Post("/admin/users/:id/reset", adminMiddleware.ThenFunc(app.adminResetUser))
+	mux.Get("/admin/events", adminMiddleware.ThenFunc(app.adminEvents))
+	mux.Post("/admin/events/:id/hide", adminMiddleware.ThenFunc(app.adminHideEvent))
+	mux.Post("/admin/events/:id/show", adminMiddleware.ThenFunc(app.adminShowEvent))
+	mux.Post("/admin/events/:id/delete", adminMiddleware.ThenFunc(app.adminDeleteEvent))
+	mux.Get("/admin/audit", adminMiddleware.ThenFunc(app.adminAudit))
+
+	// API, authenticated by the session or a token, but without the CSRF cookie
+	apiMiddleware := alice.New(app.session.Enable, app.authenticate, app.authenticateToken, app.requireJSON)
+
+	mux.Get("/api/v1/events", apiMiddleware.ThenFunc(app.apiListEvents))
+	mux.Post("/api/v1/events", apiMiddleware.Append(app.requireAPIAuthentication, app.requireAPIVerification).ThenFunc(app.apiCreateEvent))
+	mux.Get("/api/v1/events/:id", apiMiddleware.ThenFunc(app.apiShowEvent))
+	mux.Put("/api/v1/events/:id", apiMiddleware.Append(app.requireAPIAuthentication).ThenFunc(app.apiEditEvent))
+	mux.Del("/api/v1/events/:id", apiMiddleware.Append(app.requireAPIAuthentication).ThenFunc(app.apiDeleteEvent))
+	mux.Post("/api/v1/events/:id/votes", apiMiddleware.ThenFunc(app.apiVoteEvent))
 
 	return standardMiddleware.Then(mux)
 }
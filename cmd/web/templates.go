@@ -2,7 +2,9 @@ package main
 
 import (
 	"html/template"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/lobre/doodle/pkg/embeds/htmldir"
 	"github.com/lobre/doodle/pkg/forms"
@@ -13,25 +15,190 @@ import (
 )
 
 type templateData struct {
+	BaseURL         string
 	CSRFToken       string
 	CurrentYear     int
 	Flash           string
 	Form            *forms.Form
 	IsAuthenticated bool
+	IsAdmin         bool
+	IsOwner         bool
+	Location        *time.Location
+	TimeZones       []string
+	User            *models.User
 	Event           *models.Event
+	BestSlot        *models.Slot
 	Events          []*models.Event
+	Archive         []*month
+	NextPage        string
+	PrevPage        string
+	SearchTerms     []string
+	Participants    []*models.Participant
+	Tallies         map[int]*models.Tally
+	Tokens          []*models.Token
+	Users           []*models.User
+	AuditEntries    []*models.AuditEntry
+	NewToken        string
+	Reloadable      bool
+}
+
+// month holds the events happening during a month.
+type month struct {
+	Start  time.Time
+	Events []*models.Event
+}
+
+// groupByMonth splits events sorted by time into the months they happen
+// in, in the given location.
+func groupByMonth(events []*models.Event, loc *time.Location) []*month {
+	months := []*month{}
+
+	var cur *month
+	for _, evt := range events {
+		t := evt.Time.In(loc)
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+
+		if cur == nil || !cur.Start.Equal(start) {
+			cur = &month{Start: start}
+			months = append(months, cur)
+		}
+
+		cur.Events = append(cur.Events, evt)
+	}
+
+	return months
 }
 
 // humanDate returns a nicely formatted string representation
-// of a time.Time object.
+// of a time.Time object, including the abbreviation of its zone.
 func humanDate(t time.Time) string {
-	return t.Format("02 Jan 2006 at 15:04")
+	return t.Format("02 Jan 2006 at 15:04 MST")
+}
+
+// inZone converts a time.Time object to the given location,
+// which is usually the one of the viewer. UTC is used if nil.
+func inZone(loc *time.Location, t time.Time) time.Time {
+	if loc == nil {
+		return t.UTC()
+	}
+	return t.In(loc)
+}
+
+// zone returns the abbreviation of the zone of a time.Time object.
+func zone(t time.Time) string {
+	return t.Format("MST")
+}
+
+// humanDay returns the day part of a time.Time object.
+func humanDay(t time.Time) string {
+	return t.Format("Mon 02 Jan 2006")
+}
+
+// humanTime returns the hour part of a time.Time object.
+func humanTime(t time.Time) string {
+	return t.Format("15:04")
+}
+
+// slotRows pairs the slot_start and slot_end values of a form so that
+// they can be rendered back as rows of inputs. A single empty row is
+// returned when no slot has been submitted yet.
+func slotRows(f *forms.Form) [][2]string {
+	starts, ends := f.Values["slot_start"], f.Values["slot_end"]
+
+	rows := [][2]string{}
+	for i := 0; i < len(starts) || i < len(ends); i++ {
+		var row [2]string
+		if i < len(starts) {
+			row[0] = starts[i]
+		}
+		if i < len(ends) {
+			row[1] = ends[i]
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		rows = append(rows, [2]string{})
+	}
+
+	return rows
+}
+
+// highlight escapes s and wraps its words matching any of the search
+// terms in mark elements. Words are compared as the stores do, ignoring case.
+func highlight(terms []string, s string) template.HTML {
+	match := map[string]bool{}
+	for _, term := range terms {
+		match[term] = true
+	}
+
+	isWord := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+
+	var b strings.Builder
+	for s != "" {
+		// split s into the separators and the word that follows them
+		start := strings.IndexFunc(s, isWord)
+		if start < 0 {
+			start = len(s)
+		}
+		end := strings.IndexFunc(s[start:], func(r rune) bool { return !isWord(r) })
+		if end < 0 {
+			end = len(s)
+		} else {
+			end += start
+		}
+
+		b.WriteString(template.HTMLEscapeString(s[:start]))
+
+		word := s[start:end]
+		if match[strings.ToLower(word)] {
+			b.WriteString("<mark>" + template.HTMLEscapeString(word) + "</mark>")
+		} else {
+			b.WriteString(template.HTMLEscapeString(word))
+		}
+
+		s = s[end:]
+	}
+
+	return template.HTML(b.String())
 }
 
 // functions holds custom functions that we want available
 // in our templates.
 var functions = template.FuncMap{
 	"humanDate": humanDate,
+	"humanDay":  humanDay,
+	"humanTime": humanTime,
+	"inZone":    inZone,
+	"zone":      zone,
+	"slotRows":  slotRows,
+	"highlight": highlight,
+}
+
+// timeZones holds commonly used time zones, suggested in forms.
+// Any other name from the IANA database is still accepted.
+var timeZones = []string{
+	"UTC",
+	"America/Los_Angeles",
+	"America/Denver",
+	"America/Chicago",
+	"America/New_York",
+	"America/Sao_Paulo",
+	"Europe/London",
+	"Europe/Paris",
+	"Europe/Berlin",
+	"Europe/Helsinki",
+	"Europe/Moscow",
+	"Africa/Johannesburg",
+	"Asia/Dubai",
+	"Asia/Kolkata",
+	"Asia/Singapore",
+	"Asia/Shanghai",
+	"Asia/Tokyo",
+	"Australia/Sydney",
+	"Pacific/Auckland",
 }
 
 // newTemplateCache will load all template files, either from disk
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"html/template"
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name  string
+		terms []string
+		s     string
+		want  template.HTML
+	}{
+		{"Word", []string{"band"}, "Band rehearsal", "<mark>Band</mark> rehearsal"},
+		{"Several words", []string{"band", "rehearsal"}, "Band rehearsal!", "<mark>Band</mark> <mark>rehearsal</mark>!"},
+		{"Part of a word", []string{"band"}, "Bandstand", "Bandstand"},
+		{"Escaped", []string{"band"}, "<b>band</b>", "&lt;b&gt;<mark>band</mark>&lt;/b&gt;"},
+		{"No term", nil, "Tom & Jerry", "Tom &amp; Jerry"},
+		{"Accents", []string{"café"}, "Au Café", "Au <mark>Café</mark>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlight(tt.terms, tt.s); got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -1,27 +1,106 @@
 package main
 
 import (
+	"context"
+	"html"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/golangcollege/sessions"
+	"github.com/lobre/doodle/pkg/embeds/htmldir"
+	"github.com/lobre/doodle/pkg/mailer"
+	"github.com/lobre/doodle/pkg/models/memory"
+	"github.com/lobre/doodle/pkg/models/mock"
 )
 
 func newTestApplication(t *testing.T) *application {
+	// templates are loaded relatively to the root of the repository
+	htmldir.FS = http.Dir("../../ui/html")
+
+	templateCache, err := newTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := sessions.New([]byte("3dSm5MnygFHh7XidAtbskXrjbwfoJcbJ"))
+	session.Lifetime = 12 * time.Hour
+	session.Secure = true
+
 	return &application{
-		errorLog: log.New(ioutil.Discard, "", 0),
-		infoLog:  log.New(ioutil.Discard, "", 0),
+		errorLog:      log.New(ioutil.Discard, "", 0),
+		infoLog:       log.New(ioutil.Discard, "", 0),
+		session:       session,
+		publicURL:     "https://doodle.example.com",
+		eventStore:    &mock.EventStore{},
+		voteStore:     &mock.VoteStore{},
+		userStore:     &mock.UserStore{},
+		tokenStore:    &mock.TokenStore{},
+		resetStore:    &mock.ResetStore{},
+		verifStore:    &mock.VerificationStore{},
+		auditStore:    &mock.AuditStore{},
+		mailer:        &testMailer{},
+		templateCache: templateCache,
 	}
 }
 
+// newMemoryApplication returns an application backed by empty memory stores
+// instead of mocks, for tests that need data to persist across requests.
+func newMemoryApplication(t *testing.T) *application {
+	app := newTestApplication(t)
+
+	db := memory.New()
+	app.eventStore = &memory.EventStore{DB: db}
+	app.voteStore = &memory.VoteStore{DB: db}
+	app.userStore = &memory.UserStore{DB: db}
+	app.tokenStore = &memory.TokenStore{DB: db}
+	app.resetStore = &memory.ResetStore{DB: db}
+	app.verifStore = &memory.VerificationStore{DB: db}
+	app.auditStore = &memory.AuditStore{DB: db}
+
+	return app
+}
+
+// testMailer records the messages instead of sending them.
+type testMailer struct {
+	mu   sync.Mutex
+	msgs []*mailer.Message
+}
+
+func (m *testMailer) Send(ctx context.Context, msg *mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.msgs = append(m.msgs, msg)
+	return nil
+}
+
+// sentMails waits for the emails being sent by the application,
+// and returns all the messages sent so far.
+func sentMails(app *application) []*mailer.Message {
+	app.mails.Wait()
+
+	m := app.mailer.(*testMailer)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]*mailer.Message{}, m.msgs...)
+}
+
 type testServer struct {
 	*httptest.Server
 }
 
 func newTestServer(t *testing.T, h http.Handler) *testServer {
-	ts := httptest.NewServer(h)
+	ts := httptest.NewTLSServer(h)
 
 	jar, err := cookiejar.New(nil)
 	if err != nil {
@@ -52,3 +131,110 @@ func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, []byt
 
 	return rs.StatusCode, rs.Header, body
 }
+
+func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (int, http.Header, []byte) {
+	rs, err := ts.Client().PostForm(ts.URL+urlPath, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := ioutil.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	return rs.StatusCode, rs.Header, body
+}
+
+var csrfTokenRX = regexp.MustCompile(`<input type='hidden' name='csrf_token' value='(.+)'>`)
+
+// extractCSRFToken returns the CSRF token of the first form in a page.
+func extractCSRFToken(t *testing.T, body []byte) string {
+	matches := csrfTokenRX.FindSubmatch(body)
+	if len(matches) < 2 {
+		t.Fatal("no csrf token found in body")
+	}
+
+	return html.UnescapeString(string(matches[1]))
+}
+
+// login authenticates the client of the test server as the given user.
+func (ts *testServer) login(t *testing.T, email, password string) {
+	_, _, body := ts.get(t, "/user/login")
+
+	form := url.Values{}
+	form.Add("email", email)
+	form.Add("password", password)
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("could not login as %s", email)
+	}
+}
+
+// signup creates an account through the signup form, and confirms its
+// address by following the link sent by email.
+func (ts *testServer) signup(t *testing.T, app *application, name, email, password string) {
+	_, _, body := ts.get(t, "/user/signup")
+
+	form := url.Values{}
+	form.Add("name", name)
+	form.Add("email", email)
+	form.Add("password", password)
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/user/signup", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("want signup to succeed; got %d", code)
+	}
+
+	mails := sentMails(app)
+	code, _, _ = ts.get(t, mailLink(t, mails[len(mails)-1]).RequestURI())
+	if code != http.StatusSeeOther {
+		t.Fatalf("want address of %s to be confirmed; got %d", email, code)
+	}
+}
+
+var linkRX = regexp.MustCompile(`https?://\S+`)
+
+// mailLink returns the first link found in the body of a message.
+func mailLink(t *testing.T, msg *mailer.Message) *url.URL {
+	link := linkRX.FindString(msg.Body)
+	if link == "" {
+		t.Fatalf("no link found in mail %q", msg.Body)
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u
+}
+
+// do sends a request with an optional body declared as JSON.
+func (ts *testServer) do(t *testing.T, method, urlPath, body string) (int, http.Header, []byte) {
+	req, err := http.NewRequest(method, ts.URL+urlPath, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	return rs.StatusCode, rs.Header, b
+}
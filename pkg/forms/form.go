@@ -5,9 +5,16 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
+	"time"
 	"unicode/utf8"
 )
 
+// DateTimeLayout is the format of the values sent by datetime-local inputs.
+const DateTimeLayout = "2006-01-02T15:04"
+
+// DateLayout is the format of the values sent by date inputs.
+const DateLayout = "2006-01-02"
+
 // EmailRX is a regular expression for sanity checking the format
 // of an email address. This pattern is the one currently recommended
 // by the W3C and Web Hypertext Application Technology Working Group.
@@ -21,13 +28,17 @@ var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9
 type Form struct {
 	url.Values
 	Errors errors
+
+	// Location is the time zone in which dates and times
+	// are interpreted. UTC is used if nil.
+	Location *time.Location
 }
 
 // New creates a new Form taking data as entry.
 func New(data url.Values) *Form {
 	return &Form{
-		data,
-		errors(map[string][]string{}),
+		Values: data,
+		Errors: errors(map[string][]string{}),
 	}
 }
 
@@ -99,6 +110,119 @@ func (f *Form) MatchesPattern(field string, pattern *regexp.Regexp) {
 	}
 }
 
+// ValidDateTime checks that specific fields in the form contain a
+// date and a time formatted as DateTimeLayout or RFC 3339. If any fields fail this
+// check, add the appropriate message to the form errors.
+func (f *Form) ValidDateTime(fields ...string) {
+	for _, field := range fields {
+		value := f.Get(field)
+		if value == "" {
+			continue
+		}
+		if _, err := f.ParseDateTime(value); err != nil {
+			f.Errors.Add(field, "This field is not a valid date and time")
+		}
+	}
+}
+
+// FutureDateTime checks that a specific field in the form contains
+// a date and a time that has not passed yet. If the check fails, then
+// add the appropriate message to the form errors.
+func (f *Form) FutureDateTime(field string) {
+	t := f.GetTime(field)
+	if t.IsZero() {
+		return
+	}
+	if !t.After(time.Now()) {
+		f.Errors.Add(field, "This field must be in the future")
+	}
+}
+
+// MaxHorizon checks that a specific field in the form contains a
+// date and a time that is no further than d from now. If the check
+// fails, then add the appropriate message to the form errors.
+func (f *Form) MaxHorizon(field string, d time.Duration) {
+	t := f.GetTime(field)
+	if t.IsZero() {
+		return
+	}
+	if t.After(time.Now().Add(d)) {
+		f.Errors.Add(field, fmt.Sprintf("This field cannot be more than %d days from now", d/(24*time.Hour)))
+	}
+}
+
+// GetTime returns the date and time contained in a specific field in
+// the form. The zero time is returned if the field is blank or invalid.
+func (f *Form) GetTime(field string) time.Time {
+	t, err := f.ParseDateTime(f.Get(field))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// ValidDate checks that specific fields in the form contain a date
+// formatted as DateLayout. If any fields fail this check, add the
+// appropriate message to the form errors.
+func (f *Form) ValidDate(fields ...string) {
+	for _, field := range fields {
+		value := f.Get(field)
+		if value == "" {
+			continue
+		}
+		if _, err := f.parseDate(value); err != nil {
+			f.Errors.Add(field, "This field is not a valid date")
+		}
+	}
+}
+
+// GetDate returns the start of the day contained in a specific field in
+// the form. The zero time is returned if the field is blank or invalid.
+func (f *Form) GetDate(field string) time.Time {
+	t, err := f.parseDate(f.Get(field))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func (f *Form) parseDate(value string) (time.Time, error) {
+	loc := f.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	return time.ParseInLocation(DateLayout, value, loc)
+}
+
+// ValidTimeZone checks that a specific field in the form contains
+// the name of a time zone from the IANA database, such as "Europe/Paris".
+// If the check fails, then add the appropriate message to the form errors.
+func (f *Form) ValidTimeZone(field string) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+	if _, err := time.LoadLocation(value); err != nil || value == "Local" {
+		f.Errors.Add(field, "This field is not a known time zone")
+	}
+}
+
+// ParseDateTime parses a value formatted as DateTimeLayout in the
+// location of the form. Values formatted as RFC 3339, which carry their
+// own offset, are accepted as well for clients that are not browsers.
+// It is useful for fields holding several values.
+func (f *Form) ParseDateTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	loc := f.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	return time.ParseInLocation(DateTimeLayout, value, loc)
+}
+
 // Valid returns true if there are no errors in the form.
 func (f *Form) Valid() bool {
 	return len(f.Errors) == 0
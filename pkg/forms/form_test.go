@@ -0,0 +1,108 @@
+package forms
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestDateTime(t *testing.T) {
+	horizon := 365 * 24 * time.Hour
+
+	tests := []struct {
+		name      string
+		value     string
+		wantValid bool
+	}{
+		{"Valid", time.Now().Add(48 * time.Hour).Format(DateTimeLayout), true},
+		{"Blank", "", true},
+		{"Malformed", "next tuesday", false},
+		{"Date only", time.Now().Add(48 * time.Hour).Format("2006-01-02"), false},
+		{"In the past", time.Now().Add(-48 * time.Hour).Format(DateTimeLayout), false},
+		{"Beyond horizon", time.Now().Add(2 * horizon).Format(DateTimeLayout), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := New(url.Values{"time": []string{tt.value}})
+			form.ValidDateTime("time")
+			form.FutureDateTime("time")
+			form.MaxHorizon("time", horizon)
+
+			if form.Valid() != tt.wantValid {
+				t.Errorf("want valid %t; got %t (%s)", tt.wantValid, form.Valid(), form.Errors.Get("time"))
+			}
+		})
+	}
+}
+
+func TestDate(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		value     string
+		wantValid bool
+		wantTime  time.Time
+	}{
+		{"Valid", "2021-03-04", true, time.Date(2021, 3, 4, 0, 0, 0, 0, paris)},
+		{"Blank", "", true, time.Time{}},
+		{"With time", "2021-03-04T10:00", false, time.Time{}},
+		{"Malformed", "tomorrow", false, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := New(url.Values{"from": []string{tt.value}})
+			form.Location = paris
+			form.ValidDate("from")
+
+			if form.Valid() != tt.wantValid {
+				t.Errorf("want valid %t; got %t", tt.wantValid, form.Valid())
+			}
+			if got := form.GetDate("from"); !got.Equal(tt.wantTime) {
+				t.Errorf("want %v; got %v", tt.wantTime, got)
+			}
+		})
+	}
+}
+
+func TestLocation(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	form := New(url.Values{"time": []string{"2030-07-01T19:00"}})
+	form.Location = paris
+
+	want := time.Date(2030, 7, 1, 17, 0, 0, 0, time.UTC)
+	if got := form.GetTime("time"); !got.Equal(want) {
+		t.Errorf("want %s; got %s", want, got.UTC())
+	}
+}
+
+func TestValidTimeZone(t *testing.T) {
+	tests := []struct {
+		value     string
+		wantValid bool
+	}{
+		{"Europe/Paris", true},
+		{"UTC", true},
+		{"", true},
+		{"Local", false},
+		{"Mars/Olympus_Mons", false},
+	}
+
+	for _, tt := range tests {
+		form := New(url.Values{"tz": []string{tt.value}})
+		form.ValidTimeZone("tz")
+
+		if form.Valid() != tt.wantValid {
+			t.Errorf("%q: want valid %t; got %t", tt.value, tt.wantValid, form.Valid())
+		}
+	}
+}
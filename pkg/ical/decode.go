@@ -0,0 +1,384 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalid is returned when a stream is not a valid iCalendar object.
+var ErrInvalid = errors.New("ical: invalid calendar")
+
+// A Decoder reads calendars from an input stream.
+type Decoder struct {
+	r *bufio.Reader
+
+	// Location is used for floating times and dates, which are not
+	// attached to any time zone. UTC is used if nil.
+	Location *time.Location
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// property is a parsed content line.
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Decode reads the first VCALENDAR object of the stream. Components and
+// properties that are not supported are skipped.
+func (d *Decoder) Decode() (*Calendar, error) {
+	props, err := d.properties()
+	if err != nil {
+		return nil, err
+	}
+
+	var cal *Calendar
+	var evt *Event
+	var inFreeBusy bool
+
+	// components that are not supported are skipped,
+	// including their nested components
+	var skip []string
+
+	for _, p := range props {
+		if len(skip) > 0 {
+			switch {
+			case p.name == "BEGIN":
+				skip = append(skip, p.value)
+			case p.name == "END" && p.value == skip[len(skip)-1]:
+				skip = skip[:len(skip)-1]
+			}
+			continue
+		}
+
+		switch {
+		case p.name == "BEGIN" && p.value == "VCALENDAR" && cal == nil:
+			cal = &Calendar{}
+		case cal == nil:
+			return nil, fmt.Errorf("%w: missing VCALENDAR", ErrInvalid)
+
+		case p.name == "END" && p.value == "VCALENDAR":
+			return cal, nil
+
+		case p.name == "BEGIN" && p.value == "VEVENT" && evt == nil && !inFreeBusy:
+			evt = &Event{}
+		case p.name == "END" && p.value == "VEVENT" && evt != nil:
+			if evt.Start.IsZero() {
+				return nil, fmt.Errorf("%w: VEVENT without DTSTART", ErrInvalid)
+			}
+			cal.Events = append(cal.Events, evt)
+			evt = nil
+
+		case p.name == "BEGIN" && p.value == "VFREEBUSY" && evt == nil && !inFreeBusy:
+			inFreeBusy = true
+		case p.name == "END" && p.value == "VFREEBUSY" && inFreeBusy:
+			inFreeBusy = false
+
+		case p.name == "BEGIN":
+			skip = append(skip, p.value)
+		case p.name == "END":
+			return nil, fmt.Errorf("%w: unexpected END:%s", ErrInvalid, p.value)
+
+		case evt != nil:
+			if err := d.eventProperty(evt, p); err != nil {
+				return nil, err
+			}
+		case inFreeBusy && p.name == "FREEBUSY":
+			periods, err := d.periods(p)
+			if err != nil {
+				return nil, err
+			}
+			cal.Periods = append(cal.Periods, periods...)
+		case p.name == "PRODID":
+			cal.ProdID = p.value
+		case p.name == "X-WR-CALNAME":
+			cal.Name = unescape(p.value)
+		}
+	}
+
+	return nil, fmt.Errorf("%w: missing END:VCALENDAR", ErrInvalid)
+}
+
+// eventProperty sets the field of the event matching the property.
+func (d *Decoder) eventProperty(evt *Event, p *property) error {
+	var err error
+
+	switch p.name {
+	case "UID":
+		evt.UID = p.value
+	case "DTSTAMP":
+		evt.Stamp, _, err = d.parseTime(p)
+	case "DTSTART":
+		var isDate bool
+		evt.Start, isDate, err = d.parseTime(p)
+		// an event lasting a whole day ends the next day by default
+		if err == nil && isDate && evt.End.IsZero() {
+			evt.End = evt.Start.AddDate(0, 0, 1)
+		}
+	case "DTEND":
+		evt.End, _, err = d.parseTime(p)
+	case "DURATION":
+		var dur time.Duration
+		dur, err = parseDuration(p.value)
+		if err == nil {
+			if evt.Start.IsZero() {
+				return fmt.Errorf("%w: DURATION before DTSTART", ErrInvalid)
+			}
+			evt.End = evt.Start.Add(dur)
+		}
+	case "SUMMARY":
+		evt.Summary = unescape(p.value)
+	case "DESCRIPTION":
+		evt.Description = unescape(p.value)
+	case "URL":
+		evt.URL = p.value
+	case "STATUS":
+		evt.Status = strings.ToUpper(p.value)
+	}
+
+	return err
+}
+
+// periods parses a FREEBUSY property, which holds a comma separated list
+// of periods, either as start/end or start/duration.
+func (d *Decoder) periods(p *property) ([]*Period, error) {
+	fbType := strings.ToUpper(p.params["FBTYPE"])
+	if fbType == "" {
+		fbType = FreeBusyBusy
+	}
+
+	periods := []*Period{}
+
+	for _, v := range strings.Split(p.value, ",") {
+		parts := strings.SplitN(v, "/", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%w: invalid period %q", ErrInvalid, v)
+		}
+
+		start, err := time.Parse(dateTimeLayout, parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid period %q", ErrInvalid, v)
+		}
+
+		var end time.Time
+		if strings.HasPrefix(parts[1], "P") || strings.HasPrefix(parts[1], "+P") {
+			dur, err := parseDuration(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			end = start.Add(dur)
+		} else {
+			end, err = time.Parse(dateTimeLayout, parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid period %q", ErrInvalid, v)
+			}
+		}
+
+		periods = append(periods, &Period{Start: start, End: end, Type: fbType})
+	}
+
+	return periods, nil
+}
+
+// parseTime parses a DATE-TIME or DATE value, and reports whether it was a DATE.
+// Times are in UTC when suffixed with Z, in the zone given by the TZID parameter
+// if any, otherwise in the location of the decoder.
+func (d *Decoder) parseTime(p *property) (time.Time, bool, error) {
+	loc := d.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	if tzid := p.params["TZID"]; tzid != "" {
+		// some applications prefix the zone with a slash
+		l, err := time.LoadLocation(strings.TrimPrefix(tzid, "/"))
+		if err == nil {
+			loc = l
+		}
+	}
+
+	v := p.value
+
+	switch {
+	case p.params["VALUE"] == "DATE" || len(v) == len("20060102"):
+		t, err := time.ParseInLocation("20060102", v, loc)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("%w: invalid date %q", ErrInvalid, v)
+		}
+		return t, true, nil
+	case strings.HasSuffix(v, "Z"):
+		t, err := time.Parse(dateTimeLayout, v)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("%w: invalid date-time %q", ErrInvalid, v)
+		}
+		return t, false, nil
+	default:
+		t, err := time.ParseInLocation("20060102T150405", v, loc)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("%w: invalid date-time %q", ErrInvalid, v)
+		}
+		return t, false, nil
+	}
+}
+
+var durationRX = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseDuration parses a DURATION value such as P1DT2H or PT30M.
+func parseDuration(v string) (time.Duration, error) {
+	m := durationRX.FindStringSubmatch(v)
+	if m == nil || v == "P" || strings.HasSuffix(v, "T") {
+		return 0, fmt.Errorf("%w: invalid duration %q", ErrInvalid, v)
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+2] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+2])
+		if err != nil {
+			return 0, fmt.Errorf("%w: invalid duration %q", ErrInvalid, v)
+		}
+		d += time.Duration(n) * unit
+	}
+
+	if m[1] == "-" {
+		d = -d
+	}
+
+	return d, nil
+}
+
+// properties reads and unfolds all the content lines of the stream.
+func (d *Decoder) properties() ([]*property, error) {
+	props := []*property{}
+
+	var current string
+	flush := func() error {
+		if current == "" {
+			return nil
+		}
+		p, err := parseLine(current)
+		if err != nil {
+			return err
+		}
+		props = append(props, p)
+		current = ""
+		return nil
+	}
+
+	for {
+		l, err := d.r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		l = strings.TrimRight(l, "\r\n")
+
+		// a line starting with a space or a tab continues the previous one
+		if len(l) > 0 && (l[0] == ' ' || l[0] == '\t') {
+			current += l[1:]
+		} else {
+			if ferr := flush(); ferr != nil {
+				return nil, ferr
+			}
+			current = l
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return props, nil
+}
+
+// parseLine parses an unfolded content line: name *(";" param) ":" value.
+// Parameter values can be quoted to contain colons and semicolons.
+func parseLine(l string) (*property, error) {
+	p := &property{params: map[string]string{}}
+
+	i := strings.IndexAny(l, ";:")
+	if i <= 0 {
+		return nil, fmt.Errorf("%w: invalid content line %q", ErrInvalid, l)
+	}
+	p.name = strings.ToUpper(l[:i])
+
+	for l[i] == ';' {
+		l = l[i+1:]
+
+		eq := strings.IndexByte(l, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("%w: invalid parameter in %q", ErrInvalid, p.name)
+		}
+		name := strings.ToUpper(l[:eq])
+		l = l[eq+1:]
+
+		var value string
+		if strings.HasPrefix(l, `"`) {
+			end := strings.IndexByte(l[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated quote in %q", ErrInvalid, p.name)
+			}
+			value = l[1 : end+1]
+			l = l[end+2:]
+			i = 0
+		} else {
+			i = strings.IndexAny(l, ";:")
+			if i < 0 {
+				return nil, fmt.Errorf("%w: missing value in %q", ErrInvalid, p.name)
+			}
+			value = l[:i]
+			l = l[i:]
+			i = 0
+		}
+
+		if len(l) == 0 {
+			return nil, fmt.Errorf("%w: missing value in %q", ErrInvalid, p.name)
+		}
+
+		p.params[name] = value
+	}
+
+	if l[i] != ':' {
+		return nil, fmt.Errorf("%w: invalid content line for %q", ErrInvalid, p.name)
+	}
+
+	p.value = l[i+1:]
+
+	if p.name == "BEGIN" || p.name == "END" {
+		p.value = strings.ToUpper(p.value)
+	}
+
+	return p, nil
+}
+
+var unescaper = strings.NewReplacer(
+	`\\`, `\`,
+	`\;`, `;`,
+	`\,`, `,`,
+	`\n`, "\n",
+	`\N`, "\n",
+)
+
+// unescape returns the string represented by a TEXT value.
+func unescape(s string) string {
+	return unescaper.Replace(s)
+}
@@ -0,0 +1,165 @@
+package ical
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecode(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Example//Calendar//EN",
+		"BEGIN:VTIMEZONE",
+		"TZID:Europe/Paris",
+		"BEGIN:STANDARD",
+		"DTSTART:19701025T030000",
+		"END:STANDARD",
+		"END:VTIMEZONE",
+		"BEGIN:VEVENT",
+		"UID:utc@example.com",
+		"DTSTART:20300701T190000Z",
+		"DTEND:20300701T210000Z",
+		"SUMMARY:Rehearsal\\, with\\nsnacks",
+		"BEGIN:VALARM",
+		"TRIGGER:-PT15M",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:zoned@example.com",
+		"DTSTART;TZID=Europe/Paris:20300702T190000",
+		"DURATION:PT1H30M",
+		"SUMMARY:A very long summary that has been folded by the application",
+		"  at seventy five octets",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:floating@example.com",
+		"DTSTART:20300703T100000",
+		"DTEND:20300703T110000",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:allday@example.com",
+		"DTSTART;VALUE=DATE:20300704",
+		"END:VEVENT",
+		"BEGIN:VFREEBUSY",
+		"FREEBUSY;FBTYPE=FREE:20300705T080000Z/20300705T100000Z,20300706T080000Z/PT2H",
+		"FREEBUSY:20300707T080000Z/20300707T090000Z",
+		"END:VFREEBUSY",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	dec := NewDecoder(strings.NewReader(data))
+	dec.Location = paris
+
+	cal, err := dec.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cal.ProdID != "-//Example//Calendar//EN" {
+		t.Errorf("want prodid; got %q", cal.ProdID)
+	}
+
+	wantEvents := []struct {
+		uid   string
+		start time.Time
+		end   time.Time
+	}{
+		{"utc@example.com", time.Date(2030, 7, 1, 19, 0, 0, 0, time.UTC), time.Date(2030, 7, 1, 21, 0, 0, 0, time.UTC)},
+		{"zoned@example.com", time.Date(2030, 7, 2, 17, 0, 0, 0, time.UTC), time.Date(2030, 7, 2, 18, 30, 0, 0, time.UTC)},
+		{"floating@example.com", time.Date(2030, 7, 3, 8, 0, 0, 0, time.UTC), time.Date(2030, 7, 3, 9, 0, 0, 0, time.UTC)},
+		{"allday@example.com", time.Date(2030, 7, 4, 0, 0, 0, 0, paris), time.Date(2030, 7, 5, 0, 0, 0, 0, paris)},
+	}
+
+	if len(cal.Events) != len(wantEvents) {
+		t.Fatalf("want %d events; got %d", len(wantEvents), len(cal.Events))
+	}
+
+	for i, want := range wantEvents {
+		evt := cal.Events[i]
+		if evt.UID != want.uid || !evt.Start.Equal(want.start) || !evt.End.Equal(want.end) {
+			t.Errorf("want %s from %s to %s; got %s from %s to %s", want.uid, want.start, want.end, evt.UID, evt.Start, evt.End)
+		}
+	}
+
+	if cal.Events[0].Summary != "Rehearsal, with\nsnacks" {
+		t.Errorf("want unescaped summary; got %q", cal.Events[0].Summary)
+	}
+
+	if cal.Events[1].Summary != "A very long summary that has been folded by the application at seventy five octets" {
+		t.Errorf("want unfolded summary; got %q", cal.Events[1].Summary)
+	}
+
+	wantPeriods := []Period{
+		{time.Date(2030, 7, 5, 8, 0, 0, 0, time.UTC), time.Date(2030, 7, 5, 10, 0, 0, 0, time.UTC), FreeBusyFree},
+		{time.Date(2030, 7, 6, 8, 0, 0, 0, time.UTC), time.Date(2030, 7, 6, 10, 0, 0, 0, time.UTC), FreeBusyFree},
+		{time.Date(2030, 7, 7, 8, 0, 0, 0, time.UTC), time.Date(2030, 7, 7, 9, 0, 0, 0, time.UTC), FreeBusyBusy},
+	}
+
+	if len(cal.Periods) != len(wantPeriods) {
+		t.Fatalf("want %d periods; got %d", len(wantPeriods), len(cal.Periods))
+	}
+
+	for i, want := range wantPeriods {
+		p := cal.Periods[i]
+		if !p.Start.Equal(want.Start) || !p.End.Equal(want.End) || p.Type != want.Type {
+			t.Errorf("want %+v; got %+v", want, *p)
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"Empty", ""},
+		{"Not a calendar", "hello world"},
+		{"Missing end", "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"},
+		{"Event without start", "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:1\r\nEND:VEVENT\r\nEND:VCALENDAR"},
+		{"Invalid date", "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART:tomorrow\r\nEND:VEVENT\r\nEND:VCALENDAR"},
+		{"Invalid duration", "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART:20300701T190000Z\r\nDURATION:P1Y\r\nEND:VEVENT\r\nEND:VCALENDAR"},
+		{"Unterminated quote", "BEGIN:VCALENDAR\r\nX-FOO;BAR=\"baz:qux\r\nEND:VCALENDAR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewDecoder(strings.NewReader(tt.data)).Decode()
+			if !errors.Is(err, ErrInvalid) {
+				t.Errorf("want ErrInvalid; got %v", err)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"PT1H", time.Hour},
+		{"PT15M", 15 * time.Minute},
+		{"-PT15M", -15 * time.Minute},
+		{"P1DT2H30M", 26*time.Hour + 30*time.Minute},
+		{"P2W", 14 * 24 * time.Hour},
+		{"+PT10S", 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		got, err := parseDuration(tt.in)
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: want %s; got %s", tt.in, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// dateTimeLayout is the UTC form of the DATE-TIME value type.
+const dateTimeLayout = "20060102T150405Z"
+
+// maxLineLength is the number of octets after which content lines are folded.
+const maxLineLength = 75
+
+// An Encoder writes calendars to an output stream.
+type Encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: bufio.NewWriter(w)}
+}
+
+// Encode writes the iCalendar representation of c to the stream.
+func (e *Encoder) Encode(c *Calendar) error {
+	prodID := c.ProdID
+	if prodID == "" {
+		prodID = "-//lobre//doodle//EN"
+	}
+
+	e.line("BEGIN", "VCALENDAR")
+	e.line("VERSION", "2.0")
+	e.line("PRODID", prodID)
+	e.line("CALSCALE", "GREGORIAN")
+	e.line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		e.line("X-WR-CALNAME", escape(c.Name))
+	}
+
+	for _, evt := range c.Events {
+		e.encodeEvent(evt)
+	}
+
+	e.line("END", "VCALENDAR")
+
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+func (e *Encoder) encodeEvent(evt *Event) {
+	stamp := evt.Stamp
+	if stamp.IsZero() {
+		stamp = time.Now()
+	}
+
+	e.line("BEGIN", "VEVENT")
+	e.line("UID", evt.UID)
+	e.line("DTSTAMP", formatTime(stamp))
+	e.line("DTSTART", formatTime(evt.Start))
+	if !evt.End.IsZero() {
+		e.line("DTEND", formatTime(evt.End))
+	}
+	e.line("SUMMARY", escape(evt.Summary))
+	if evt.Description != "" {
+		e.line("DESCRIPTION", escape(evt.Description))
+	}
+	if evt.URL != "" {
+		e.line("URL", evt.URL)
+	}
+	if evt.Status != "" {
+		e.line("STATUS", evt.Status)
+	}
+	e.line("END", "VEVENT")
+}
+
+// line writes a content line, folded if longer than maxLineLength octets.
+// The value must already be escaped.
+func (e *Encoder) line(name, value string) {
+	if e.err != nil {
+		return
+	}
+
+	l := name + ":" + value
+
+	// continuation lines start with a space, which counts in their length
+	limit := maxLineLength
+	for len(l) > limit {
+		// never split a multi-byte character
+		i := limit
+		for i > 0 && !utf8.RuneStart(l[i]) {
+			i--
+		}
+
+		_, e.err = fmt.Fprintf(e.w, "%s\r\n ", l[:i])
+		if e.err != nil {
+			return
+		}
+
+		l = l[i:]
+		limit = maxLineLength - 1
+	}
+
+	_, e.err = fmt.Fprintf(e.w, "%s\r\n", l)
+}
+
+// formatTime returns the UTC form of a DATE-TIME value.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(dateTimeLayout)
+}
+
+var escaper = strings.NewReplacer(
+	`\`, `\\`,
+	`;`, `\;`,
+	`,`, `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+)
+
+// escape returns the TEXT representation of s.
+func escape(s string) string {
+	return escaper.Replace(s)
+}
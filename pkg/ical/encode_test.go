@@ -0,0 +1,82 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	start := time.Date(2030, 7, 1, 19, 0, 0, 0, time.UTC)
+
+	cal := &Calendar{
+		Name: "Doodle",
+		Events: []*Event{
+			{
+				UID:         "event-1-slot-1@example.com",
+				Stamp:       start.Add(-24 * time.Hour),
+				Start:       start,
+				End:         start.Add(2 * time.Hour),
+				Summary:     "Jam session; bring drums, guitars",
+				Description: strings.Repeat("A long description with accents éèà\n", 5),
+				URL:         "https://example.com/event/1",
+				Status:      StatusTentative,
+			},
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	if err := NewEncoder(buf).Encode(cal); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, l := range strings.Split(buf.String(), "\r\n") {
+		if len(l) > maxLineLength {
+			t.Errorf("line is longer than %d octets: %q", maxLineLength, l)
+		}
+	}
+
+	got, err := NewDecoder(buf).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Name != cal.Name {
+		t.Errorf("want name %q; got %q", cal.Name, got.Name)
+	}
+
+	if len(got.Events) != 1 {
+		t.Fatalf("want 1 event; got %d", len(got.Events))
+	}
+
+	want, evt := cal.Events[0], got.Events[0]
+
+	if evt.UID != want.UID || evt.Summary != want.Summary || evt.Description != want.Description ||
+		evt.URL != want.URL || evt.Status != want.Status {
+		t.Errorf("want %+v; got %+v", want, evt)
+	}
+
+	if !evt.Stamp.Equal(want.Stamp) || !evt.Start.Equal(want.Start) || !evt.End.Equal(want.End) {
+		t.Errorf("want times %s, %s, %s; got %s, %s, %s", want.Stamp, want.Start, want.End, evt.Stamp, evt.Start, evt.End)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{"a;b,c", `a\;b\,c`},
+		{`back\slash`, `back\\slash`},
+		{"two\nlines", `two\nlines`},
+		{"two\r\nlines", `two\nlines`},
+	}
+
+	for _, tt := range tests {
+		if got := escape(tt.in); got != tt.want {
+			t.Errorf("escape(%q): want %q; got %q", tt.in, tt.want, got)
+		}
+	}
+}
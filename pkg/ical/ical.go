@@ -0,0 +1,49 @@
+// Package ical implements a subset of the iCalendar format defined by
+// RFC 5545, enough to exchange events with calendar applications.
+package ical
+
+import "time"
+
+// Status values of an event.
+const (
+	StatusTentative = "TENTATIVE"
+	StatusConfirmed = "CONFIRMED"
+	StatusCancelled = "CANCELLED"
+)
+
+// Free/busy types of a period.
+const (
+	FreeBusyFree            = "FREE"
+	FreeBusyBusy            = "BUSY"
+	FreeBusyBusyUnavailable = "BUSY-UNAVAILABLE"
+	FreeBusyBusyTentative   = "BUSY-TENTATIVE"
+)
+
+// Calendar is a VCALENDAR object, holding a list of events. Periods
+// gathers the FREEBUSY properties of all its VFREEBUSY components.
+type Calendar struct {
+	ProdID  string
+	Name    string
+	Events  []*Event
+	Periods []*Period
+}
+
+// Event is a VEVENT component. Times are always written in UTC.
+type Event struct {
+	UID         string
+	Stamp       time.Time
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	URL         string
+	Status      string
+}
+
+// Period is a time range of a VFREEBUSY component,
+// with its free/busy type such as FreeBusyFree.
+type Period struct {
+	Start time.Time
+	End   time.Time
+	Type  string
+}
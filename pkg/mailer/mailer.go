@@ -0,0 +1,114 @@
+// Package mailer sends the emails of the application, such as the links
+// to reset a password. Messages are plain text.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Message is an email to send.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends messages. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// SMTP sends messages through an SMTP server, which is expected to
+// support STARTTLS when a username is set.
+type SMTP struct {
+	Addr     string // host:port of the server
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTP) Send(ctx context.Context, msg *Message) error {
+	host, _, err := net.SplitHostPort(m.Addr)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return err
+	}
+
+	// net/smtp does not take a context, so the deadline is only
+	// checked before sending
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	return smtp.SendMail(m.Addr, auth, from.Address, []string{msg.To}, format(m.From, msg, time.Now()))
+}
+
+// Log writes messages to a logger instead of sending them, which is
+// handy during development.
+type Log struct {
+	Logger *log.Logger
+	From   string
+}
+
+func (m *Log) Send(ctx context.Context, msg *Message) error {
+	m.Logger.Printf("Mail to %s\n%s", msg.To, format(m.From, msg, time.Now()))
+	return nil
+}
+
+// Dir writes each message to its own .eml file in a directory instead of
+// sending it. Such files can be opened by most mail clients.
+type Dir struct {
+	Path string
+	From string
+
+	count int64
+}
+
+func (m *Dir) Send(ctx context.Context, msg *Message) error {
+	if err := os.MkdirAll(m.Path, 0755); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("%s-%d.eml", now.Format("20060102-150405"), atomic.AddInt64(&m.count, 1))
+
+	return ioutil.WriteFile(filepath.Join(m.Path, name), format(m.From, msg, now), 0644)
+}
+
+// format returns the message as sent over the wire, headers included.
+func format(from string, msg *Message, date time.Time) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+
+	return b.Bytes()
+}
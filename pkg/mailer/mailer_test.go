@@ -0,0 +1,123 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testMessage = &Message{
+	To:      "alice@example.com",
+	Subject: "Réinitialisation",
+	Body:    "Hello Alice,\nFollow this link.",
+}
+
+func TestDir(t *testing.T) {
+	dir := t.TempDir()
+	m := &Dir{Path: dir, From: "Doodle <doodle@example.com>"}
+
+	for i := 0; i < 2; i++ {
+		if err := m.Send(context.Background(), testMessage); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("want 2 files; got %d", len(files))
+	}
+
+	b, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"From: Doodle <doodle@example.com>\r\n",
+		"To: alice@example.com\r\n",
+		"Subject: =?utf-8?q?R=C3=A9initialisation?=\r\n",
+		"\r\n\r\nHello Alice,\r\nFollow this link.",
+	} {
+		if !bytes.Contains(b, []byte(want)) {
+			t.Errorf("want message to contain %q; got %q", want, b)
+		}
+	}
+}
+
+func TestSMTP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	received := make(chan []string, 1)
+	go serveSMTP(t, l, received)
+
+	m := &SMTP{Addr: l.Addr().String(), From: "Doodle <doodle@example.com>"}
+	if err := m.Send(context.Background(), testMessage); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Join(<-received, "\n")
+	for _, want := range []string{
+		"MAIL FROM:<doodle@example.com>",
+		"RCPT TO:<alice@example.com>",
+		"Follow this link.",
+	} {
+		if !strings.Contains(lines, want) {
+			t.Errorf("want session to contain %q; got %q", want, lines)
+		}
+	}
+}
+
+// serveSMTP accepts a single connection and answers just enough of the
+// protocol for a message to be sent. The received lines are reported.
+func serveSMTP(t *testing.T, l net.Listener, received chan<- []string) {
+	conn, err := l.Accept()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	lines := []string{}
+	defer func() { received <- lines }()
+
+	tp.PrintfLine("220 localhost ESMTP")
+
+	data := false
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		lines = append(lines, line)
+
+		switch {
+		case data && line == ".":
+			data = false
+			tp.PrintfLine("250 OK")
+		case data:
+		case strings.HasPrefix(line, "EHLO"):
+			tp.PrintfLine("250 localhost")
+		case line == "DATA":
+			data = true
+			tp.PrintfLine("354 Go ahead")
+		case line == "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("250 OK")
+		}
+	}
+}
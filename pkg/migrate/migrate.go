@@ -0,0 +1,210 @@
+// Package migrate applies versioned schema migrations to a database, and
+// keeps track of the ones already applied in the schema_migrations table.
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNoChange is returned when there is no migration to revert.
+var ErrNoChange = errors.New("migrate: no migration to revert")
+
+// Migration is a numbered change of the schema. Up and Down can hold
+// several statements, each ending with a semicolon at the end of a line.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes a migration and when it has been applied.
+// Applied is zero when the migration is pending.
+type Status struct {
+	Migration
+	Applied time.Time
+}
+
+// Migrator applies a set of migrations to a database. The statements
+// of the tracking table are portable across MySQL, PostgreSQL and SQLite.
+// Status and Pending only read the database, so that the schema can be
+// checked by a user who is not allowed to change it.
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+}
+
+// Status returns all the known migrations ordered by version.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []Status{}
+	for _, mig := range m.sorted() {
+		statuses = append(statuses, Status{Migration: mig, Applied: applied[mig.Version]})
+	}
+
+	return statuses, nil
+}
+
+// Pending returns the migrations that are not applied yet, ordered by version.
+func (m *Migrator) Pending() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []Migration{}
+	for _, mig := range m.sorted() {
+		if _, ok := applied[mig.Version]; !ok {
+			pending = append(pending, mig)
+		}
+	}
+
+	return pending, nil
+}
+
+// Up applies all the pending migrations and returns them.
+func (m *Migrator) Up() ([]Migration, error) {
+	stmt := `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		applied TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`
+
+	if _, err := m.DB.Exec(stmt); err != nil {
+		return nil, err
+	}
+
+	pending, err := m.Pending()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, mig := range pending {
+		stmt := fmt.Sprintf("INSERT INTO schema_migrations (version) VALUES (%d)", mig.Version)
+		if err := m.exec(mig.Up, stmt); err != nil {
+			return pending[:i], fmt.Errorf("migrate: applying %d_%s: %w", mig.Version, mig.Name, err)
+		}
+	}
+
+	return pending, nil
+}
+
+// Down reverts the last applied migration and returns it.
+func (m *Migrator) Down() (*Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := m.sorted()
+	for i := len(sorted) - 1; i >= 0; i-- {
+		mig := sorted[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+
+		stmt := fmt.Sprintf("DELETE FROM schema_migrations WHERE version = %d", mig.Version)
+		if err := m.exec(mig.Down, stmt); err != nil {
+			return nil, fmt.Errorf("migrate: reverting %d_%s: %w", mig.Version, mig.Name, err)
+		}
+
+		return &mig, nil
+	}
+
+	return nil, ErrNoChange
+}
+
+// applied returns the versions of the applied migrations along with
+// the time they have been applied. Nothing has been applied as long as
+// the tracking table does not exist.
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	applied := map[int]time.Time{}
+
+	rows, err := m.DB.Query(`SELECT version, applied FROM schema_migrations`)
+	if err != nil {
+		if missingTable(err) {
+			return applied, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var t time.Time
+
+		if err = rows.Scan(&version, &t); err != nil {
+			return nil, err
+		}
+
+		applied[version] = t
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+// exec runs the statements of a migration followed by the update of the
+// tracking table in a transaction. Note that MySQL commits implicitly
+// after each change of the schema, so a failing migration can be left
+// half applied there.
+func (m *Migrator) exec(script, track string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range append(statements(script), track) {
+		if _, err = tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// missingTable reports whether err is the one of MySQL, PostgreSQL or SQLite
+// for a table that does not exist, as the drivers have no common error.
+func missingTable(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "Error 1146") ||
+		strings.Contains(msg, "relation") && strings.Contains(msg, "does not exist") ||
+		strings.Contains(msg, "no such table")
+}
+
+// sorted returns the migrations ordered by version.
+func (m *Migrator) sorted() []Migration {
+	sorted := make([]Migration, len(m.Migrations))
+	copy(sorted, m.Migrations)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+
+	return sorted
+}
+
+// statements splits a script into statements, as not all drivers
+// accept several of them in a single call.
+func statements(script string) []string {
+	stmts := []string{}
+	for _, stmt := range strings.Split(script, ";\n") {
+		stmt = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(stmt), ";"))
+		if stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
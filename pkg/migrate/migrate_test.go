@@ -0,0 +1,200 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var testMigrations = []Migration{
+	{
+		Version: 2,
+		Name:    "create_pets",
+		Up:      "CREATE TABLE pets (id INTEGER PRIMARY KEY, person_id INTEGER NOT NULL REFERENCES people(id));",
+		Down:    "DROP TABLE pets;",
+	},
+	{
+		Version: 1,
+		Name:    "create_people",
+		Up: `
+CREATE TABLE people (id INTEGER PRIMARY KEY, name TEXT NOT NULL);
+CREATE INDEX idx_people_name ON people(name);
+`,
+		Down: "DROP TABLE people;",
+	},
+}
+
+func newTestMigrator(t *testing.T, migrations []Migration) *Migrator {
+	db, err := sql.Open("sqlite3", "file:"+t.TempDir()+"/test.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	return &Migrator{DB: db, Migrations: migrations}
+}
+
+func versions(migrations []Migration) []int {
+	v := []int{}
+	for _, m := range migrations {
+		v = append(v, m.Version)
+	}
+	return v
+}
+
+func TestMigrator(t *testing.T) {
+	m := newTestMigrator(t, testMigrations)
+
+	pending, err := m.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 2}; !reflect.DeepEqual(versions(pending), want) {
+		t.Errorf("want pending %v; got %v", want, versions(pending))
+	}
+
+	applied, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 2}; !reflect.DeepEqual(versions(applied), want) {
+		t.Errorf("want applied %v; got %v", want, versions(applied))
+	}
+
+	_, err = m.DB.Exec("INSERT INTO pets (person_id) VALUES (1)")
+	if err != nil {
+		t.Errorf("want schema to be migrated; got %s", err)
+	}
+
+	applied, err = m.Up()
+	if err != nil || len(applied) != 0 {
+		t.Errorf("want nothing to apply; got %v, %v", versions(applied), err)
+	}
+
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if s.Applied.IsZero() {
+			t.Errorf("want migration %d to be applied", s.Version)
+		}
+	}
+
+	reverted, err := m.Down()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reverted.Version != 2 {
+		t.Errorf("want migration 2 to be reverted; got %d", reverted.Version)
+	}
+
+	statuses, err = m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !statuses[1].Applied.IsZero() || statuses[0].Applied.IsZero() {
+		t.Errorf("want only migration 1 to be applied")
+	}
+
+	if _, err = m.Down(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = m.Down(); !errors.Is(err, ErrNoChange) {
+		t.Errorf("want %q; got %v", ErrNoChange, err)
+	}
+}
+
+func TestReadOnlyStatus(t *testing.T) {
+	path := t.TempDir() + "/test.db"
+
+	db, err := sql.Open("sqlite3", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// a database created before migrations existed
+	if _, err = db.Exec("CREATE TABLE people (id INTEGER PRIMARY KEY, name TEXT NOT NULL)"); err != nil {
+		t.Fatal(err)
+	}
+
+	ro, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+
+	m := &Migrator{DB: ro, Migrations: testMigrations}
+
+	pending, err := m.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 2}; !reflect.DeepEqual(versions(pending), want) {
+		t.Errorf("want pending %v; got %v", want, versions(pending))
+	}
+
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if !s.Applied.IsZero() {
+			t.Errorf("want migration %d to be pending", s.Version)
+		}
+	}
+
+	if _, err = m.Down(); !errors.Is(err, ErrNoChange) {
+		t.Errorf("want %q; got %v", ErrNoChange, err)
+	}
+}
+
+func TestMigratorFailure(t *testing.T) {
+	m := newTestMigrator(t, append([]Migration{{
+		Version: 3,
+		Name:    "broken",
+		Up:      "ALTER TABLE nothing ADD COLUMN x TEXT;",
+	}}, testMigrations...))
+
+	applied, err := m.Up()
+	if err == nil {
+		t.Fatal("want an error")
+	}
+	if want := []int{1, 2}; !reflect.DeepEqual(versions(applied), want) {
+		t.Errorf("want applied %v; got %v", want, versions(applied))
+	}
+
+	pending, err := m.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{3}; !reflect.DeepEqual(versions(pending), want) {
+		t.Errorf("want pending %v; got %v", want, versions(pending))
+	}
+}
+
+func TestStatements(t *testing.T) {
+	script := `
+CREATE TABLE a (id INTEGER);
+
+CREATE TABLE b (
+    id INTEGER
+);
+DROP TABLE c`
+
+	want := []string{
+		"CREATE TABLE a (id INTEGER)",
+		"CREATE TABLE b (\n    id INTEGER\n)",
+		"DROP TABLE c",
+	}
+
+	if got := statements(script); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %q; got %q", want, got)
+	}
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Action is something an administrator did, as recorded in the audit trail.
+type Action string
+
+const (
+	ActionActivateUser   Action = "user.activate"
+	ActionDeactivateUser Action = "user.deactivate"
+	ActionPromoteUser    Action = "user.promote"
+	ActionDemoteUser     Action = "user.demote"
+	ActionResetUser      Action = "user.reset"
+	ActionHideEvent      Action = "event.hide"
+	ActionShowEvent      Action = "event.show"
+	ActionDeleteEvent    Action = "event.delete"
+)
+
+// Target returns the kind of record the action applies to, user or event.
+func (a Action) Target() string {
+	if i := strings.Index(string(a), "."); i >= 0 {
+		return string(a[:i])
+	}
+	return ""
+}
+
+// AuditEntry records an action of an administrator on a user or an event.
+// AdminID is 0 for actions taken from the command line, or once the
+// administrator has been erased, while TargetID is kept even when the
+// target is gone.
+type AuditEntry struct {
+	ID        int
+	AdminID   int
+	AdminName string
+	Action    Action
+	TargetID  int
+	Created   time.Time
+}
+
+// AuditPage is a page of the audit trail, the latest entries first. Next
+// is the ID to pass to get the following page, or 0 if this one is the last.
+type AuditPage struct {
+	Entries []*AuditEntry
+	Next    int
+}
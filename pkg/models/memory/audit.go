@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/lobre/doodle/pkg/models"
+)
+
+type AuditStore struct {
+	DB *DB
+}
+
+var _ models.AuditStore = (*AuditStore)(nil)
+
+func (m *AuditStore) Insert(ctx context.Context, adminID int, action models.Action, targetID int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	m.DB.lastAuditID++
+
+	m.DB.audits = append(m.DB.audits, &models.AuditEntry{
+		ID:       m.DB.lastAuditID,
+		AdminID:  adminID,
+		Action:   action,
+		TargetID: targetID,
+		Created:  time.Now().UTC(),
+	})
+
+	return nil
+}
+
+// List returns a page of the audit trail, the latest entries first,
+// starting before the entry of the given ID, if any.
+func (m *AuditStore) List(ctx context.Context, before, limit int) (*models.AuditPage, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	if limit < 1 {
+		limit = models.DefaultLimit
+	}
+
+	page := &models.AuditPage{Entries: []*models.AuditEntry{}}
+
+	// entries are appended in order of ID
+	for i := len(m.DB.audits) - 1; i >= 0; i-- {
+		e := *m.DB.audits[i]
+		if before > 0 && e.ID >= before {
+			continue
+		}
+
+		if len(page.Entries) == limit {
+			page.Next = page.Entries[limit-1].ID
+			break
+		}
+
+		if u, ok := m.DB.users[e.AdminID]; ok {
+			e.AdminName = u.Name
+		}
+		page.Entries = append(page.Entries, &e)
+	}
+
+	return page, nil
+}
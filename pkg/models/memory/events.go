@@ -0,0 +1,287 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lobre/doodle/pkg/models"
+)
+
+type EventStore struct {
+	DB *DB
+}
+
+var _ models.EventStore = (*EventStore)(nil)
+
+func (m *EventStore) Insert(ctx context.Context, userID int, title, desc string, t time.Time, tz string, slots []*models.Slot) (int, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	m.DB.lastEventID++
+
+	evt := &models.Event{
+		ID:       m.DB.lastEventID,
+		UserID:   userID,
+		Title:    title,
+		Desc:     desc,
+		Time:     t.UTC(),
+		TimeZone: tz,
+		Status:   models.StatusOpen,
+	}
+
+	for _, s := range slots {
+		m.DB.lastSlotID++
+		evt.Slots = append(evt.Slots, &models.Slot{ID: m.DB.lastSlotID, Start: s.Start.UTC(), End: s.End.UTC()})
+	}
+
+	// slots are kept ordered chronologically
+	sort.SliceStable(evt.Slots, func(i, j int) bool {
+		if evt.Slots[i].Start.Equal(evt.Slots[j].Start) {
+			return evt.Slots[i].End.Before(evt.Slots[j].End)
+		}
+		return evt.Slots[i].Start.Before(evt.Slots[j].Start)
+	})
+
+	m.DB.events[evt.ID] = evt
+
+	return evt.ID, nil
+}
+
+func (m *EventStore) Get(ctx context.Context, id int) (*models.Event, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	evt, ok := m.DB.events[id]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	return copyEvent(evt), nil
+}
+
+func (m *EventStore) Upcoming(ctx context.Context) ([]*models.Event, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	now := time.Now()
+	events := []*models.Event{}
+
+	for _, evt := range m.DB.events {
+		if evt.Time.After(now) && !evt.Hidden {
+			events = append(events, copyEvent(evt))
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return before(events[i].Time, events[i].ID, events[j].Time, events[j].ID)
+	})
+
+	if len(events) > 10 {
+		events = events[:10]
+	}
+
+	return events, nil
+}
+
+// List returns a page of upcoming or past events matching the filter.
+func (m *EventStore) List(ctx context.Context, f models.EventFilter) (*models.EventPage, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	if f.Limit < 1 {
+		f.Limit = models.DefaultLimit
+	}
+
+	now := time.Now()
+	events := []*models.Event{}
+
+	for _, evt := range m.DB.events {
+		switch {
+		case evt.Time.After(now) == f.Past,
+			!f.From.IsZero() && evt.Time.Before(f.From),
+			!f.To.IsZero() && !evt.Time.Before(f.To),
+			f.UserID > 0 && evt.UserID != f.UserID,
+			f.Title != "" && !strings.Contains(strings.ToLower(evt.Title), strings.ToLower(f.Title)),
+			evt.Hidden && !f.Hidden:
+			continue
+		}
+
+		if f.After != nil {
+			if f.Desc && !before(evt.Time, evt.ID, f.After.Time, f.After.ID) {
+				continue
+			}
+			if !f.Desc && !before(f.After.Time, f.After.ID, evt.Time, evt.ID) {
+				continue
+			}
+		}
+
+		events = append(events, copyEvent(evt))
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		if f.Desc {
+			i, j = j, i
+		}
+		return before(events[i].Time, events[i].ID, events[j].Time, events[j].ID)
+	})
+
+	page := &models.EventPage{Events: events}
+
+	if len(events) > f.Limit {
+		page.Events = events[:f.Limit]
+		page.Next = models.CursorOf(page.Events[f.Limit-1])
+	}
+
+	return page, nil
+}
+
+// Search returns a page of upcoming events containing all the terms of the
+// query, ranked by relevance.
+func (m *EventStore) Search(ctx context.Context, query string, offset, limit int) (*models.SearchResults, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	if limit < 1 {
+		limit = models.DefaultLimit
+	}
+
+	now := time.Now()
+	events := []*models.Event{}
+
+	for _, evt := range m.DB.events {
+		if evt.Time.After(now) && !evt.Hidden {
+			events = append(events, copyEvent(evt))
+		}
+	}
+
+	return models.RankSearch(events, models.SearchTerms(query), offset, limit), nil
+}
+
+// ForUser returns all events created by the user or on which they voted,
+// along with their slots, ordered by time. Hidden events are left out.
+func (m *EventStore) ForUser(ctx context.Context, userID int) ([]*models.Event, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	voted := map[int]bool{}
+	for _, p := range m.DB.participants {
+		if p.UserID == userID {
+			voted[p.EventID] = true
+		}
+	}
+
+	events := []*models.Event{}
+
+	for _, evt := range m.DB.events {
+		if (evt.UserID == userID || voted[evt.ID]) && !evt.Hidden {
+			events = append(events, copyEvent(evt))
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+
+	return events, nil
+}
+
+// Update changes the title and the description of an event.
+func (m *EventStore) Update(ctx context.Context, id int, title, desc string) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if evt, ok := m.DB.events[id]; ok {
+		evt.Title, evt.Desc = title, desc
+	}
+
+	return nil
+}
+
+// Delete removes an event along with its slots and votes.
+func (m *EventStore) Delete(ctx context.Context, id int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	m.DB.deleteEvent(id)
+
+	return nil
+}
+
+// Purge removes the events that happened before the given time, along
+// with their votes. It returns the number of events removed.
+func (m *EventStore) Purge(ctx context.Context, before time.Time) (int, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	n := 0
+	for id, evt := range m.DB.events {
+		if evt.Time.Before(before) {
+			m.DB.deleteEvent(id)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// deleteEvent removes an event and its participants. The caller must hold the lock.
+func (db *DB) deleteEvent(id int) {
+	delete(db.events, id)
+
+	participants := db.participants[:0]
+	for _, p := range db.participants {
+		if p.EventID != id {
+			participants = append(participants, p)
+		}
+	}
+	db.participants = participants
+}
+
+// Close marks the poll of an event as decided on the given slot.
+func (m *EventStore) Close(ctx context.Context, id, slotID int) error {
+	return m.setStatus(id, models.StatusClosed, slotID)
+}
+
+// Cancel marks an event as cancelled.
+func (m *EventStore) Cancel(ctx context.Context, id int) error {
+	return m.setStatus(id, models.StatusCancelled, 0)
+}
+
+// Reopen allows participants to vote again on a closed or cancelled event.
+func (m *EventStore) Reopen(ctx context.Context, id int) error {
+	return m.setStatus(id, models.StatusOpen, 0)
+}
+
+// SetHidden hides an event from everyone but administrators, or shows it again.
+func (m *EventStore) SetHidden(ctx context.Context, id int, hidden bool) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if evt, ok := m.DB.events[id]; ok {
+		evt.Hidden = hidden
+	}
+
+	return nil
+}
+
+func (m *EventStore) setStatus(id int, status models.Status, slotID int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if evt, ok := m.DB.events[id]; ok {
+		evt.Status, evt.FinalSlotID = status, slotID
+	}
+
+	return nil
+}
+
+// before reports whether an event at time t1 with ID id1 is listed before
+// one at time t2 with ID id2, in ascending order.
+func before(t1 time.Time, id1 int, t2 time.Time, id2 int) bool {
+	if t1.Equal(t2) {
+		return id1 < id2
+	}
+	return t1.Before(t2)
+}
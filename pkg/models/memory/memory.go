@@ -0,0 +1,97 @@
+// Package memory implements the stores in memory. Data is lost when the
+// application stops, which makes it handy for development and tests.
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lobre/doodle/pkg/models"
+)
+
+// DB holds the data shared by the stores of this package. The stores
+// only hand out copies, so that callers cannot change the data behind
+// the back of the lock.
+type DB struct {
+	mu sync.RWMutex
+
+	events       map[int]*models.Event
+	participants []*models.Participant
+	users        map[int]*models.User
+	tokens       map[int]*token
+	resets       map[string]*reset
+	verifs       map[int]*verification
+	audits       []*models.AuditEntry
+
+	lastEventID       int
+	lastSlotID        int
+	lastParticipantID int
+	lastUserID        int
+	lastTokenID       int
+	lastAuditID       int
+}
+
+// token is a stored API token along with its hash.
+type token struct {
+	models.Token
+	hash string
+}
+
+// reset is a stored password reset token, indexed by its hash.
+type reset struct {
+	userID  int
+	expires time.Time
+}
+
+// verification is the last token sent to confirm the address of a user,
+// indexed by the ID of the user. The hash is cleared once used.
+type verification struct {
+	email    string
+	hash     string
+	sent     time.Time
+	expires  time.Time
+	verified bool
+}
+
+// New returns an empty database.
+func New() *DB {
+	return &DB{
+		events: map[int]*models.Event{},
+		users:  map[int]*models.User{},
+		tokens: map[int]*token{},
+		resets: map[string]*reset{},
+		verifs: map[int]*verification{},
+	}
+}
+
+func copyEvent(evt *models.Event) *models.Event {
+	c := *evt
+	c.Slots = make([]*models.Slot, len(evt.Slots))
+	for i, s := range evt.Slots {
+		slot := *s
+		c.Slots[i] = &slot
+	}
+	return &c
+}
+
+func copyParticipant(p *models.Participant) *models.Participant {
+	c := *p
+	c.Answers = make(map[int]models.Answer, len(p.Answers))
+	for slotID, answer := range p.Answers {
+		c.Answers[slotID] = answer
+	}
+	return &c
+}
+
+// copyUser leaves the hashed password out, as the other stores do. The
+// address is verified as long as it is the one the token was sent to.
+// The caller must hold the lock.
+func (db *DB) copyUser(u *models.User) *models.User {
+	c := *u
+	c.HashedPassword = nil
+
+	v, ok := db.verifs[u.ID]
+	c.Verified = ok && v.verified && v.email == u.Email
+
+	return &c
+}
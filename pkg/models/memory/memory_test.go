@@ -0,0 +1,23 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/lobre/doodle/pkg/models/storetest"
+)
+
+func TestStores(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) *storetest.Stores {
+		db := New()
+
+		return &storetest.Stores{
+			Events: &EventStore{DB: db},
+			Votes:  &VoteStore{DB: db},
+			Users:  &UserStore{DB: db},
+			Tokens: &TokenStore{DB: db},
+			Resets: &ResetStore{DB: db},
+			Verifs: &VerificationStore{DB: db},
+			Audit:  &AuditStore{DB: db},
+		}
+	})
+}
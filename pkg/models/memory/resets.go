@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/lobre/doodle/pkg/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type ResetStore struct {
+	DB *DB
+}
+
+var _ models.ResetStore = (*ResetStore)(nil)
+
+// Insert saves a token allowing the active user with the given email
+// to reset their password, until it expires.
+func (m *ResetStore) Insert(ctx context.Context, email, token string, expires time.Time) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	for _, u := range m.DB.users {
+		if u.Email == email && u.Active {
+			m.DB.resets[models.HashToken(token)] = &reset{userID: u.ID, expires: expires}
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
+
+// Check verifies that a token can still be used, without using it.
+func (m *ResetStore) Check(ctx context.Context, token string) error {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	_, err := m.user(token)
+	return err
+}
+
+// Reset changes the password of the user owning the token. All the tokens
+// of the user are dropped, so that none of them can be used again. Their
+// sessions and API tokens are ended as well.
+func (m *ResetStore) Reset(ctx context.Context, token, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	u, err := m.user(token)
+	if err != nil {
+		return err
+	}
+
+	u.HashedPassword = hashedPassword
+
+	for hash, r := range m.DB.resets {
+		if r.userID == u.ID {
+			delete(m.DB.resets, hash)
+		}
+	}
+
+	m.DB.endSessions(u)
+
+	return nil
+}
+
+// user returns the active user owning a valid token.
+// The caller must hold the lock.
+func (m *ResetStore) user(token string) (*models.User, error) {
+	r, ok := m.DB.resets[models.HashToken(token)]
+	if !ok || !r.expires.After(time.Now()) {
+		return nil, models.ErrInvalidCredentials
+	}
+
+	u, ok := m.DB.users[r.userID]
+	if !ok || !u.Active {
+		return nil, models.ErrInvalidCredentials
+	}
+
+	return u, nil
+}
@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/lobre/doodle/pkg/models"
+)
+
+type TokenStore struct {
+	DB *DB
+}
+
+var _ models.TokenStore = (*TokenStore)(nil)
+
+func (m *TokenStore) Insert(ctx context.Context, userID int, name, secret string) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	m.DB.lastTokenID++
+
+	m.DB.tokens[m.DB.lastTokenID] = &token{
+		Token: models.Token{
+			ID:      m.DB.lastTokenID,
+			UserID:  userID,
+			Name:    name,
+			Created: time.Now().UTC(),
+		},
+		hash: models.HashToken(secret),
+	}
+
+	return nil
+}
+
+func (m *TokenStore) ForUser(ctx context.Context, userID int) ([]*models.Token, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	tokens := []*models.Token{}
+
+	for _, t := range m.DB.tokens {
+		if t.UserID == userID {
+			c := t.Token
+			tokens = append(tokens, &c)
+		}
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].ID > tokens[j].ID
+	})
+
+	return tokens, nil
+}
+
+// Delete revokes a token. The user is given so that users cannot
+// revoke the tokens of someone else.
+func (m *TokenStore) Delete(ctx context.Context, id, userID int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	t, ok := m.DB.tokens[id]
+	if !ok || t.UserID != userID {
+		return models.ErrNoRecord
+	}
+
+	delete(m.DB.tokens, id)
+
+	return nil
+}
+
+// Authenticate returns the ID of the user owning the given token,
+// and records when it has been used for the last time.
+func (m *TokenStore) Authenticate(ctx context.Context, secret string) (int, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	hash := models.HashToken(secret)
+
+	for _, t := range m.DB.tokens {
+		if t.hash == hash {
+			t.LastUsed = time.Now().UTC()
+			return t.UserID, nil
+		}
+	}
+
+	return 0, models.ErrInvalidCredentials
+}
@@ -0,0 +1,377 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lobre/doodle/pkg/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type UserStore struct {
+	DB *DB
+}
+
+var _ models.UserStore = (*UserStore)(nil)
+
+func (m *UserStore) Insert(ctx context.Context, name, email, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	for _, u := range m.DB.users {
+		if u.Email == email {
+			return models.ErrDuplicateEmail
+		}
+	}
+
+	m.DB.lastUserID++
+
+	m.DB.users[m.DB.lastUserID] = &models.User{
+		ID:             m.DB.lastUserID,
+		Name:           name,
+		Email:          email,
+		HashedPassword: hashedPassword,
+		Created:        time.Now().UTC(),
+		Active:         true,
+		Role:           models.RoleUser,
+	}
+
+	return nil
+}
+
+func (m *UserStore) Authenticate(ctx context.Context, email, password string) (int, error) {
+	m.DB.mu.RLock()
+	var id int
+	var hashedPassword []byte
+	for _, u := range m.DB.users {
+		if u.Email == email && u.Active {
+			id, hashedPassword = u.ID, u.HashedPassword
+			break
+		}
+	}
+	m.DB.mu.RUnlock()
+
+	if id == 0 {
+		return 0, models.ErrInvalidCredentials
+	}
+
+	// a hash is replaced rather than modified when the password changes,
+	// so it can be compared without holding the lock during this slow step
+	err := bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return 0, models.ErrInvalidCredentials
+		} else {
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
+func (m *UserStore) Get(ctx context.Context, id int) (*models.User, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	u, ok := m.DB.users[id]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	return m.DB.copyUser(u), nil
+}
+
+func (m *UserStore) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	for _, u := range m.DB.users {
+		if u.Email == email {
+			return m.DB.copyUser(u), nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+// List returns a page of users matching the filter, sorted by ID.
+func (m *UserStore) List(ctx context.Context, f models.UserFilter) (*models.UserPage, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	if f.Limit < 1 {
+		f.Limit = models.DefaultLimit
+	}
+
+	query := strings.ToLower(f.Query)
+	users := []*models.User{}
+
+	for _, u := range m.DB.users {
+		switch {
+		case u.ID <= f.After,
+			!strings.Contains(strings.ToLower(u.Name), query) && !strings.Contains(strings.ToLower(u.Email), query):
+			continue
+		}
+
+		users = append(users, m.DB.copyUser(u))
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+
+	page := &models.UserPage{Users: users}
+
+	if len(users) > f.Limit {
+		page.Users = users[:f.Limit]
+		page.Next = page.Users[f.Limit-1].ID
+	}
+
+	return page, nil
+}
+
+func (m *UserStore) SetTimeZone(ctx context.Context, id int, tz string) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if u, ok := m.DB.users[id]; ok {
+		u.TimeZone = tz
+	}
+
+	return nil
+}
+
+func (m *UserStore) SetFeedToken(ctx context.Context, id int, token string) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if u, ok := m.DB.users[id]; ok {
+		u.FeedToken = token
+	}
+
+	return nil
+}
+
+// GetByFeedToken returns the active user owning the given calendar feed token.
+func (m *UserStore) GetByFeedToken(ctx context.Context, token string) (*models.User, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	for _, u := range m.DB.users {
+		if token != "" && u.FeedToken == token && u.Active {
+			return m.DB.copyUser(u), nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (m *UserStore) SetName(ctx context.Context, id int, name string) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if u, ok := m.DB.users[id]; ok {
+		u.Name = name
+	}
+
+	return nil
+}
+
+// SetEmail changes the address of the user, which is unverified until
+// a token sent to the new address comes back.
+func (m *UserStore) SetEmail(ctx context.Context, id int, email string) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	for _, u := range m.DB.users {
+		if u.Email == email && u.ID != id {
+			return models.ErrDuplicateEmail
+		}
+	}
+
+	if u, ok := m.DB.users[id]; ok {
+		u.Email = email
+	}
+
+	return nil
+}
+
+// ChangePassword replaces the password of the active user, provided the
+// current one is given. Pending password resets are dropped along, and
+// the sessions and API tokens of the user are ended.
+func (m *UserStore) ChangePassword(ctx context.Context, id int, current, password string) error {
+	m.DB.mu.RLock()
+	var hashedPassword []byte
+	if u, ok := m.DB.users[id]; ok && u.Active {
+		hashedPassword = u.HashedPassword
+	}
+	m.DB.mu.RUnlock()
+
+	if hashedPassword == nil {
+		return models.ErrInvalidCredentials
+	}
+
+	err := bcrypt.CompareHashAndPassword(hashedPassword, []byte(current))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return models.ErrInvalidCredentials
+		} else {
+			return err
+		}
+	}
+
+	hashedPassword, err = bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	u, ok := m.DB.users[id]
+	if !ok {
+		return nil
+	}
+
+	u.HashedPassword = hashedPassword
+
+	for hash, r := range m.DB.resets {
+		if r.userID == id {
+			delete(m.DB.resets, hash)
+		}
+	}
+
+	m.DB.endSessions(u)
+
+	return nil
+}
+
+// Activate enables the account of the user again.
+func (m *UserStore) Activate(ctx context.Context, id int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if u, ok := m.DB.users[id]; ok {
+		u.Active = true
+	}
+
+	return nil
+}
+
+// Deactivate disables the account of the user, who cannot log in anymore.
+// Their data is kept.
+func (m *UserStore) Deactivate(ctx context.Context, id int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if m.DB.lastAdmin(id) {
+		return models.ErrLastAdmin
+	}
+
+	if u, ok := m.DB.users[id]; ok {
+		u.Active = false
+	}
+
+	return nil
+}
+
+func (m *UserStore) SetRole(ctx context.Context, id int, role models.Role) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if role != models.RoleAdmin && m.DB.lastAdmin(id) {
+		return models.ErrLastAdmin
+	}
+
+	if u, ok := m.DB.users[id]; ok {
+		u.Role = role
+	}
+
+	return nil
+}
+
+// Erase permanently deletes the user along with their events. Their votes
+// on the events of others are kept for the polls to stay accurate, but
+// under an anonymous name and no longer linked to them.
+func (m *UserStore) Erase(ctx context.Context, id int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if m.DB.lastAdmin(id) {
+		return models.ErrLastAdmin
+	}
+
+	for eventID, evt := range m.DB.events {
+		if evt.UserID == id {
+			m.DB.deleteEvent(eventID)
+		}
+	}
+
+	for _, p := range m.DB.participants {
+		if p.UserID == id {
+			p.UserID = 0
+			p.Name = fmt.Sprintf("%s #%d", models.ErasedName, p.ID)
+		}
+	}
+
+	for tokenID, t := range m.DB.tokens {
+		if t.UserID == id {
+			delete(m.DB.tokens, tokenID)
+		}
+	}
+
+	for hash, r := range m.DB.resets {
+		if r.userID == id {
+			delete(m.DB.resets, hash)
+		}
+	}
+
+	for _, e := range m.DB.audits {
+		if e.AdminID == id {
+			e.AdminID = 0
+		}
+	}
+
+	delete(m.DB.verifs, id)
+	delete(m.DB.users, id)
+
+	return nil
+}
+
+// endSessions raises the session version of the user, which ends the
+// sessions started before, and deletes their API tokens.
+// The caller must hold the lock.
+func (db *DB) endSessions(u *models.User) {
+	u.SessionVersion++
+
+	for id, t := range db.tokens {
+		if t.UserID == u.ID {
+			delete(db.tokens, id)
+		}
+	}
+}
+
+// lastAdmin reports whether the user is the only active administrator.
+// The caller must hold the lock.
+func (db *DB) lastAdmin(id int) bool {
+	if u, ok := db.users[id]; !ok || !u.Active || u.Role != models.RoleAdmin {
+		return false
+	}
+
+	for _, u := range db.users {
+		if u.ID != id && u.Active && u.Role == models.RoleAdmin {
+			return false
+		}
+	}
+
+	return true
+}
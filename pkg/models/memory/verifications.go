@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/lobre/doodle/pkg/models"
+)
+
+type VerificationStore struct {
+	DB *DB
+}
+
+var _ models.VerificationStore = (*VerificationStore)(nil)
+
+// Insert saves a token confirming the current address of the active user
+// with the given email, in place of any previous one, until it expires.
+func (m *VerificationStore) Insert(ctx context.Context, email, token string, expires time.Time) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	for _, u := range m.DB.users {
+		if u.Email == email && u.Active {
+			m.DB.verifs[u.ID] = &verification{
+				email:   email,
+				hash:    models.HashToken(token),
+				sent:    time.Now().UTC(),
+				expires: expires,
+			}
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
+
+// Sent returns when the last token was sent to the user.
+func (m *VerificationStore) Sent(ctx context.Context, userID int) (time.Time, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	if v, ok := m.DB.verifs[userID]; ok {
+		return v.sent, nil
+	}
+
+	return time.Time{}, nil
+}
+
+// Verify marks the address the token was sent to as verified, if it is
+// still the address of the user, and returns the ID of the user.
+func (m *VerificationStore) Verify(ctx context.Context, token string) (int, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	hash := models.HashToken(token)
+
+	for id, v := range m.DB.verifs {
+		if v.hash == "" || v.hash != hash || !v.expires.After(time.Now()) {
+			continue
+		}
+
+		u, ok := m.DB.users[id]
+		if !ok || !u.Active || u.Email != v.email {
+			return 0, models.ErrInvalidCredentials
+		}
+
+		v.hash, v.verified = "", true
+		return id, nil
+	}
+
+	return 0, models.ErrInvalidCredentials
+}
@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/lobre/doodle/pkg/models"
+)
+
+type VoteStore struct {
+	DB *DB
+}
+
+var _ models.VoteStore = (*VoteStore)(nil)
+
+// Upsert saves the answers of a participant. Participants are identified
+// by their name within an event, so that voting again under the same name
+// replaces the previous answers. The userID is optional and can be 0. The
+// answers given by a user under a name can only be replaced by that user,
+// and a participant never changes hands.
+func (m *VoteStore) Upsert(ctx context.Context, eventID, userID int, name string, answers map[int]models.Answer) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	var p *models.Participant
+	for _, cur := range m.DB.participants {
+		if cur.EventID == eventID && cur.Name == name {
+			p = cur
+			break
+		}
+	}
+
+	if p == nil {
+		m.DB.lastParticipantID++
+		p = &models.Participant{
+			ID:      m.DB.lastParticipantID,
+			EventID: eventID,
+			UserID:  userID,
+			Name:    name,
+			Answers: map[int]models.Answer{},
+		}
+		m.DB.participants = append(m.DB.participants, p)
+	} else if p.UserID != 0 && p.UserID != userID {
+		return models.ErrNameTaken
+	}
+
+	p.Updated = time.Now().UTC()
+
+	for slotID, answer := range answers {
+		p.Answers[slotID] = answer
+	}
+
+	return nil
+}
+
+// ForEvent returns the participants of an event with their answers,
+// in the order they first voted.
+func (m *VoteStore) ForEvent(ctx context.Context, eventID int) ([]*models.Participant, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	participants := []*models.Participant{}
+
+	for _, p := range m.DB.participants {
+		if p.EventID == eventID {
+			participants = append(participants, copyParticipant(p))
+		}
+	}
+
+	return participants, nil
+}
@@ -0,0 +1,32 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	"github.com/lobre/doodle/pkg/models"
+)
+
+var mockAuditEntry = &models.AuditEntry{
+	ID:        1,
+	AdminID:   4,
+	AdminName: "Carol",
+	Action:    models.ActionDeactivateUser,
+	TargetID:  2,
+	Created:   time.Now(),
+}
+
+type AuditStore struct{}
+
+var _ models.AuditStore = (*AuditStore)(nil)
+
+func (m *AuditStore) Insert(ctx context.Context, adminID int, action models.Action, targetID int) error {
+	return nil
+}
+
+func (m *AuditStore) List(ctx context.Context, before, limit int) (*models.AuditPage, error) {
+	if before > 0 {
+		return &models.AuditPage{Entries: []*models.AuditEntry{}}, nil
+	}
+	return &models.AuditPage{Entries: []*models.AuditEntry{mockAuditEntry}}, nil
+}
@@ -1,33 +1,130 @@
 package mock
 
 import (
+	"context"
 	"time"
 
 	"github.com/lobre/doodle/pkg/models"
 )
 
 var mockEvent = &models.Event{
-	ID:    1,
-	Title: "Music festival",
-	Desc:  "Happening every year, and always fun.",
-	Time:  time.Now(),
+	ID:     1,
+	UserID: 1,
+	Title:  "Music festival",
+	Desc:   "Happening every year, and always fun.",
+	Time:   time.Now().Add(24 * time.Hour),
+	Status: models.StatusOpen,
+	Slots: []*models.Slot{
+		{ID: 1, Start: time.Now().Add(24 * time.Hour), End: time.Now().Add(26 * time.Hour)},
+		{ID: 2, Start: time.Now().Add(48 * time.Hour), End: time.Now().Add(50 * time.Hour)},
+	},
+}
+
+var mockPastEvent = &models.Event{
+	ID:     3,
+	UserID: 1,
+	Title:  "Winter concert",
+	Desc:   "It was cold, but worth it.",
+	Time:   time.Now().Add(-24 * time.Hour),
+	Status: models.StatusClosed,
+	Slots: []*models.Slot{
+		{ID: 3, Start: time.Now().Add(-24 * time.Hour), End: time.Now().Add(-22 * time.Hour)},
+	},
+	FinalSlotID: 3,
+}
+
+var mockHiddenEvent = &models.Event{
+	ID:     4,
+	UserID: 2,
+	Title:  "Cheap watches",
+	Desc:   "Buy now!",
+	Time:   time.Now().Add(24 * time.Hour),
+	Status: models.StatusOpen,
+	Hidden: true,
+	Slots: []*models.Slot{
+		{ID: 4, Start: time.Now().Add(24 * time.Hour), End: time.Now().Add(26 * time.Hour)},
+	},
 }
 
 type EventStore struct{}
 
-func (m *EventStore) Insert(title, desc, time string) (int, error) {
-	return 2, nil
+var _ models.EventStore = (*EventStore)(nil)
+
+func (m *EventStore) Insert(ctx context.Context, userID int, title, desc string, t time.Time, tz string, slots []*models.Slot) (int, error) {
+	return 1, nil
 }
 
-func (m *EventStore) Get(id int) (*models.Event, error) {
+func (m *EventStore) Get(ctx context.Context, id int) (*models.Event, error) {
 	switch id {
 	case 1:
 		return mockEvent, nil
+	case 3:
+		return mockPastEvent, nil
+	case 4:
+		return mockHiddenEvent, nil
+	case 503:
+		// simulates a database too slow to answer
+		return nil, models.ErrTimeout
 	default:
 		return nil, models.ErrNoRecord
 	}
 }
 
-func (m *EventStore) Upcoming() ([]*models.Event, error) {
+func (m *EventStore) Upcoming(ctx context.Context) ([]*models.Event, error) {
 	return []*models.Event{mockEvent}, nil
 }
+
+func (m *EventStore) List(ctx context.Context, f models.EventFilter) (*models.EventPage, error) {
+	if f.UserID > 1 || f.After != nil {
+		return &models.EventPage{Events: []*models.Event{}}, nil
+	}
+	if f.Past {
+		return &models.EventPage{Events: []*models.Event{mockPastEvent}}, nil
+	}
+	if f.Hidden {
+		return &models.EventPage{Events: []*models.Event{mockEvent, mockHiddenEvent}}, nil
+	}
+	return &models.EventPage{Events: []*models.Event{mockEvent}}, nil
+}
+
+func (m *EventStore) Search(ctx context.Context, query string, offset, limit int) (*models.SearchResults, error) {
+	terms := models.SearchTerms(query)
+	return models.RankSearch([]*models.Event{mockEvent}, terms, offset, limit), nil
+}
+
+func (m *EventStore) ForUser(ctx context.Context, userID int) ([]*models.Event, error) {
+	switch userID {
+	case 1:
+		return []*models.Event{mockEvent}, nil
+	default:
+		return []*models.Event{}, nil
+	}
+}
+
+func (m *EventStore) Update(ctx context.Context, id int, title, desc string) error {
+	return nil
+}
+
+func (m *EventStore) Delete(ctx context.Context, id int) error {
+	return nil
+}
+
+func (m *EventStore) Purge(ctx context.Context, before time.Time) (int, error) {
+	return 0, nil
+}
+
+func (m *EventStore) Close(ctx context.Context, id, slotID int) error {
+	return nil
+}
+
+func (m *EventStore) Cancel(ctx context.Context, id int) error {
+	return nil
+}
+
+func (m *EventStore) Reopen(ctx context.Context, id int) error {
+	return nil
+}
+
+func (m *EventStore) SetHidden(ctx context.Context, id int, hidden bool) error {
+	return nil
+}
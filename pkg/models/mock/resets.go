@@ -0,0 +1,34 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	"github.com/lobre/doodle/pkg/models"
+)
+
+type ResetStore struct{}
+
+var _ models.ResetStore = (*ResetStore)(nil)
+
+func (m *ResetStore) Insert(ctx context.Context, email, token string, expires time.Time) error {
+	switch email {
+	case "alice@example.com", "bob@example.com":
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *ResetStore) Check(ctx context.Context, token string) error {
+	switch token {
+	case "alice-reset-token":
+		return nil
+	default:
+		return models.ErrInvalidCredentials
+	}
+}
+
+func (m *ResetStore) Reset(ctx context.Context, token, password string) error {
+	return m.Check(ctx, token)
+}
@@ -0,0 +1,50 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	"github.com/lobre/doodle/pkg/models"
+)
+
+var mockToken = &models.Token{
+	ID:      1,
+	UserID:  1,
+	Name:    "Deploy bot",
+	Created: time.Now(),
+}
+
+type TokenStore struct{}
+
+var _ models.TokenStore = (*TokenStore)(nil)
+
+func (m *TokenStore) Insert(ctx context.Context, userID int, name, token string) error {
+	return nil
+}
+
+func (m *TokenStore) ForUser(ctx context.Context, userID int) ([]*models.Token, error) {
+	switch userID {
+	case 1:
+		return []*models.Token{mockToken}, nil
+	default:
+		return []*models.Token{}, nil
+	}
+}
+
+func (m *TokenStore) Delete(ctx context.Context, id, userID int) error {
+	if id == mockToken.ID && userID == mockToken.UserID {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *TokenStore) Authenticate(ctx context.Context, token string) (int, error) {
+	switch token {
+	case "alice-api-token":
+		return 1, nil
+	case "bob-api-token":
+		return 2, nil
+	default:
+		return 0, models.ErrInvalidCredentials
+	}
+}
@@ -1,22 +1,48 @@
 package mock
 
 import (
+	"context"
+	"strings"
 	"time"
 
 	"github.com/lobre/doodle/pkg/models"
 )
 
 var mockUser = &models.User{
-	ID:      1,
-	Name:    "Alice",
-	Email:   "alice@example.com",
+	ID:        1,
+	Name:      "Alice",
+	Email:     "alice@example.com",
+	Created:   time.Now(),
+	Active:    true,
+	Verified:  true,
+	Role:      models.RoleUser,
+	FeedToken: "alice-feed-token",
+}
+
+var mockOtherUser = &models.User{
+	ID:      2,
+	Name:    "Bob",
+	Email:   "bob@example.com",
 	Created: time.Now(),
 	Active:  true,
+	Role:    models.RoleUser,
+}
+
+var mockAdmin = &models.User{
+	ID:       4,
+	Name:     "Carol",
+	Email:    "carol@example.com",
+	Created:  time.Now(),
+	Active:   true,
+	Verified: true,
+	Role:     models.RoleAdmin,
 }
 
 type UserStore struct{}
 
-func (m *UserStore) Insert(name, email, password string) error {
+var _ models.UserStore = (*UserStore)(nil)
+
+func (m *UserStore) Insert(ctx context.Context, name, email, password string) error {
 	switch email {
 	case "dupe@example.com":
 		return models.ErrDuplicateEmail
@@ -25,20 +51,119 @@ func (m *UserStore) Insert(name, email, password string) error {
 	}
 }
 
-func (m *UserStore) Authenticate(email, password string) (int, error) {
+func (m *UserStore) Authenticate(ctx context.Context, email, password string) (int, error) {
+	if password == "wrong-password" {
+		return 0, models.ErrInvalidCredentials
+	}
+
 	switch email {
 	case "alice@example.com":
 		return 1, nil
+	case "bob@example.com":
+		return 2, nil
+	case "carol@example.com":
+		return 4, nil
+	case "slow@example.com":
+		return 503, nil
 	default:
 		return 0, models.ErrInvalidCredentials
 	}
 }
 
-func (m *UserStore) Get(id int) (*models.User, error) {
+func (m *UserStore) Get(ctx context.Context, id int) (*models.User, error) {
 	switch id {
 	case 1:
 		return mockUser, nil
+	case 2:
+		return mockOtherUser, nil
+	case 4:
+		return mockAdmin, nil
+	case 503:
+		return nil, models.ErrTimeout
 	default:
 		return nil, models.ErrNoRecord
 	}
 }
+
+func (m *UserStore) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	for _, u := range []*models.User{mockUser, mockOtherUser, mockAdmin} {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *UserStore) List(ctx context.Context, f models.UserFilter) (*models.UserPage, error) {
+	page := &models.UserPage{Users: []*models.User{}}
+	for _, u := range []*models.User{mockUser, mockOtherUser, mockAdmin} {
+		if u.ID > f.After && strings.Contains(strings.ToLower(u.Name+" "+u.Email), strings.ToLower(f.Query)) {
+			page.Users = append(page.Users, u)
+		}
+	}
+	return page, nil
+}
+
+func (m *UserStore) SetTimeZone(ctx context.Context, id int, tz string) error {
+	return nil
+}
+
+func (m *UserStore) SetFeedToken(ctx context.Context, id int, token string) error {
+	return nil
+}
+
+func (m *UserStore) GetByFeedToken(ctx context.Context, token string) (*models.User, error) {
+	switch token {
+	case "alice-feed-token":
+		return mockUser, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *UserStore) SetName(ctx context.Context, id int, name string) error {
+	return nil
+}
+
+func (m *UserStore) SetEmail(ctx context.Context, id int, email string) error {
+	switch email {
+	case "dupe@example.com", mockOtherUser.Email:
+		return models.ErrDuplicateEmail
+	default:
+		return nil
+	}
+}
+
+func (m *UserStore) ChangePassword(ctx context.Context, id int, current, password string) error {
+	switch current {
+	case "wrong-password":
+		return models.ErrInvalidCredentials
+	default:
+		return nil
+	}
+}
+
+func (m *UserStore) Activate(ctx context.Context, id int) error {
+	return nil
+}
+
+func (m *UserStore) Deactivate(ctx context.Context, id int) error {
+	if id == mockAdmin.ID {
+		return models.ErrLastAdmin
+	}
+	return nil
+}
+
+func (m *UserStore) SetRole(ctx context.Context, id int, role models.Role) error {
+	if id == mockAdmin.ID && role != models.RoleAdmin {
+		return models.ErrLastAdmin
+	}
+	return nil
+}
+
+func (m *UserStore) Erase(ctx context.Context, id int) error {
+	if id == mockAdmin.ID {
+		return models.ErrLastAdmin
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	"github.com/lobre/doodle/pkg/models"
+)
+
+// mockSent is when a verification email was last sent to Bob, whose
+// address is not confirmed yet.
+var mockSent = time.Now()
+
+type VerificationStore struct{}
+
+var _ models.VerificationStore = (*VerificationStore)(nil)
+
+func (m *VerificationStore) Insert(ctx context.Context, email, token string, expires time.Time) error {
+	switch email {
+	case "alice@example.com", "bob@example.com":
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *VerificationStore) Sent(ctx context.Context, userID int) (time.Time, error) {
+	switch userID {
+	case 2:
+		return mockSent, nil
+	default:
+		return time.Time{}, nil
+	}
+}
+
+func (m *VerificationStore) Verify(ctx context.Context, token string) (int, error) {
+	switch token {
+	case "bob-verify-token":
+		return 2, nil
+	default:
+		return 0, models.ErrInvalidCredentials
+	}
+}
@@ -0,0 +1,40 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	"github.com/lobre/doodle/pkg/models"
+)
+
+var mockParticipant = &models.Participant{
+	ID:      1,
+	EventID: 1,
+	Name:    "Bob",
+	Updated: time.Now(),
+	Answers: map[int]models.Answer{
+		1: models.AnswerYes,
+		2: models.AnswerIfNeedBe,
+	},
+}
+
+type VoteStore struct{}
+
+var _ models.VoteStore = (*VoteStore)(nil)
+
+func (m *VoteStore) Upsert(ctx context.Context, eventID, userID int, name string, answers map[int]models.Answer) error {
+	// Alice voted under her own name
+	if name == "Alice" && userID != 1 {
+		return models.ErrNameTaken
+	}
+	return nil
+}
+
+func (m *VoteStore) ForEvent(ctx context.Context, eventID int) ([]*models.Participant, error) {
+	switch eventID {
+	case 1:
+		return []*models.Participant{mockParticipant}, nil
+	default:
+		return []*models.Participant{}, nil
+	}
+}
@@ -1,7 +1,13 @@
 package models
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -9,15 +15,245 @@ var (
 	ErrNoRecord           = errors.New("models: no matching record found")
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 	ErrDuplicateEmail     = errors.New("models: duplicate email")
+	ErrNameTaken          = errors.New("models: name taken by another user")
+	ErrLastAdmin          = errors.New("models: last active administrator")
+	ErrTimeout            = errors.New("models: query timed out")
+	ErrInvalidCursor      = errors.New("models: invalid cursor")
+)
+
+// QueryContext bounds ctx with the given timeout, when positive. The returned
+// function releases the context. It also replaces the error pointed by errp
+// with ErrTimeout when the deadline has been exceeded, as drivers report it
+// in different ways.
+func QueryContext(ctx context.Context, timeout time.Duration) (context.Context, func(errp *error)) {
+	cancel := func() {}
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	return ctx, func(errp *error) {
+		if *errp != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			*errp = ErrTimeout
+		}
+		cancel()
+	}
+}
+
+// Status is the stage of the lifecycle an event is in.
+type Status string
+
+const (
+	// StatusOpen means participants can still vote.
+	StatusOpen Status = "open"
+	// StatusClosed means the owner has picked the final slot.
+	StatusClosed Status = "closed"
+	// StatusCancelled means the event will not happen.
+	StatusCancelled Status = "cancelled"
 )
 
 type Event struct {
+	ID          int
+	UserID      int
+	Title       string
+	Desc        string
+	Time        time.Time
+	TimeZone    string
+	Status      Status
+	FinalSlotID int
+	Hidden      bool
+	Slots       []*Slot
+}
+
+// Location returns the time zone of the event, or nil if the organiser
+// did not set any.
+func (e *Event) Location() *time.Location {
+	if e.TimeZone == "" {
+		return nil
+	}
+	loc, err := time.LoadLocation(e.TimeZone)
+	if err != nil {
+		return nil
+	}
+	return loc
+}
+
+// Past returns true if the event has already happened. Past events
+// are kept read-only, for the record.
+func (e *Event) Past() bool {
+	return !e.Time.After(time.Now())
+}
+
+// FinalSlot returns the slot picked when closing the event,
+// or nil if none has been picked.
+func (e *Event) FinalSlot() *Slot {
+	for _, s := range e.Slots {
+		if s.ID == e.FinalSlotID {
+			return s
+		}
+	}
+	return nil
+}
+
+// DefaultLimit is the number of events in a page when the filter sets none.
+const DefaultLimit = 20
+
+// EventFilter narrows down the events returned by EventStore.List.
+// Zero values mean no restriction.
+type EventFilter struct {
+	From   time.Time // events on or after
+	To     time.Time // events strictly before
+	UserID int       // events created by this user
+	Title  string    // events whose title contains this, ignoring case
+	Past   bool      // past events instead of upcoming ones
+	Hidden bool      // hidden events as well, for administrators
+	Desc   bool      // latest events first
+	After  *Cursor   // events following this position
+	Limit  int
+}
+
+// EventPage is a page of events. Next is the cursor to pass to get
+// the following page, or nil if this one is the last.
+type EventPage struct {
+	Events []*Event
+	Next   *Cursor
+}
+
+// Cursor is the position of an event in a listing sorted by time. Events
+// sharing the same time are sorted by ID, so that pages never overlap,
+// even when events are inserted in between.
+type Cursor struct {
+	Time time.Time
+	ID   int
+}
+
+// CursorOf returns the position of an event.
+func CursorOf(evt *Event) *Cursor {
+	return &Cursor{Time: evt.Time, ID: evt.ID}
+}
+
+// String encodes the cursor as an opaque token, safe to use in URLs.
+func (c *Cursor) String() string {
+	s := fmt.Sprintf("%d.%d", c.Time.UnixNano(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+// ParseCursor decodes a token returned by Cursor.String.
+// It returns ErrInvalidCursor if the token is malformed.
+func ParseCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var nsec int64
+	var id int
+	if _, err = fmt.Sscanf(string(b), "%d.%d", &nsec, &id); err != nil || id < 1 {
+		return nil, ErrInvalidCursor
+	}
+
+	return &Cursor{Time: time.Unix(0, nsec).UTC(), ID: id}, nil
+}
+
+// Slot is a candidate time range proposed by the organiser of an event.
+type Slot struct {
 	ID    int
-	Title string
-	Desc  string
-	Time  time.Time
+	Start time.Time
+	End   time.Time
+}
+
+// Answer is the availability given by a participant for a slot.
+type Answer string
+
+const (
+	AnswerYes      Answer = "yes"
+	AnswerIfNeedBe Answer = "ifneedbe"
+	AnswerNo       Answer = "no"
+)
+
+// Participant is someone who answered the poll of an event.
+// Answers are indexed by slot ID.
+type Participant struct {
+	ID      int
+	EventID int
+	UserID  int
+	Name    string
+	Updated time.Time
+	Answers map[int]Answer
+}
+
+// ErasedName replaces the name of the participants whose account has been
+// erased, followed by the ID of the participant. Names starting with it are
+// reserved, so that these participants stay locked.
+const ErasedName = "Deleted user"
+
+// Tally holds the number of each answer given for a slot.
+type Tally struct {
+	Yes      int
+	IfNeedBe int
+	No       int
+}
+
+// Tallies counts the answers of all participants, indexed by slot ID.
+func Tallies(participants []*Participant) map[int]*Tally {
+	tallies := map[int]*Tally{}
+
+	for _, p := range participants {
+		for slotID, answer := range p.Answers {
+			t, ok := tallies[slotID]
+			if !ok {
+				t = &Tally{}
+				tallies[slotID] = t
+			}
+
+			switch answer {
+			case AnswerYes:
+				t.Yes++
+			case AnswerIfNeedBe:
+				t.IfNeedBe++
+			case AnswerNo:
+				t.No++
+			}
+		}
+	}
+
+	return tallies
+}
+
+// BestSlot suggests the slot with the most yes answers, using if-need-be
+// answers and then the earliest start to break ties. It returns nil if there
+// are no slots or nobody has said yes or if-need-be to any of them.
+func BestSlot(slots []*Slot, tallies map[int]*Tally) *Slot {
+	var best *Slot
+	var bestTally Tally
+
+	for _, s := range slots {
+		t, ok := tallies[s.ID]
+		if !ok || t.Yes+t.IfNeedBe == 0 {
+			continue
+		}
+
+		switch {
+		case best == nil,
+			t.Yes > bestTally.Yes,
+			t.Yes == bestTally.Yes && t.IfNeedBe > bestTally.IfNeedBe,
+			t.Yes == bestTally.Yes && t.IfNeedBe == bestTally.IfNeedBe && s.Start.Before(best.Start):
+			best, bestTally = s, *t
+		}
+	}
+
+	return best
 }
 
+// Role grants rights to a user beyond their own events.
+type Role string
+
+const (
+	// RoleUser is the role of every user by default.
+	RoleUser Role = "user"
+	// RoleAdmin lets users manage the accounts and the events of others.
+	RoleAdmin Role = "admin"
+)
+
 type User struct {
 	ID             int
 	Name           string
@@ -25,4 +261,55 @@ type User struct {
 	HashedPassword []byte
 	Created        time.Time
 	Active         bool
+	Verified       bool
+	Role           Role
+	TimeZone       string
+	FeedToken      string
+	// SessionVersion is raised when the password changes, so that the
+	// sessions started with the previous one can be told apart.
+	SessionVersion int
+}
+
+// IsAdmin returns true if the user is an administrator.
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// UserFilter narrows down the users returned by UserStore.List.
+// Zero values mean no restriction.
+type UserFilter struct {
+	Query string // users whose name or email contains this, ignoring case
+	After int    // users following this ID
+	Limit int
+}
+
+// UserPage is a page of users sorted by ID. Next is the ID to pass
+// to get the following page, or 0 if this one is the last.
+type UserPage struct {
+	Users []*User
+	Next  int
+}
+
+// ContainsPattern returns a LIKE pattern matching the lowercase strings
+// containing s, using ! as the escape character.
+func ContainsPattern(s string) string {
+	r := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+	return "%" + r.Replace(strings.ToLower(s)) + "%"
+}
+
+// Token is a personal API token. Only its hash is stored, so the
+// secret itself cannot be shown again once created.
+type Token struct {
+	ID       int
+	UserID   int
+	Name     string
+	Created  time.Time
+	LastUsed time.Time
+}
+
+// HashToken returns the hash under which a token is stored. Tokens are long
+// random strings, so a fast hash is enough to protect them.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
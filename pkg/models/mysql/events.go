@@ -1,42 +1,77 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/lobre/doodle/pkg/models"
 )
 
 type EventStore struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Timeout time.Duration
 }
 
-func (m *EventStore) Insert(title, desc, time string) (int, error) {
-	stmt := `INSERT INTO events (title, description, time)
-	VALUES (?, ?, DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+var _ models.EventStore = (*EventStore)(nil)
 
-	result, err := m.DB.Exec(stmt, title, desc, time)
+func (m *EventStore) Insert(ctx context.Context, userID int, title, desc string, t time.Time, tz string, slots []*models.Slot) (_ int, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt := `INSERT INTO events (user_id, title, description, time, time_zone)
+	VALUES (?, ?, ?, ?, ?)`
+
+	result, err := tx.ExecContext(ctx, stmt, userID, title, desc, t.UTC(), tz)
 	if err != nil {
+		tx.Rollback()
 		return 0, err
 	}
 
 	id, err := result.LastInsertId()
 	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	stmt = `INSERT INTO slots (event_id, start_time, end_time) VALUES (?, ?, ?)`
+
+	for _, s := range slots {
+		_, err = tx.ExecContext(ctx, stmt, id, s.Start.UTC(), s.End.UTC())
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
 		return 0, err
 	}
 
 	return int(id), nil
 }
 
-func (m *EventStore) Get(id int) (*models.Event, error) {
-	stmt := `SELECT id, title, description, time FROM events
-	WHERE time > UTC_TIMESTAMP() AND id = ?`
+func (m *EventStore) Get(ctx context.Context, id int) (_ *models.Event, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
 
-	row := m.DB.QueryRow(stmt, id)
+	stmt := `SELECT id, COALESCE(user_id, 0), title, description, time, time_zone, status, COALESCE(final_slot_id, 0),
+	EXISTS (SELECT 1 FROM hidden_events h WHERE h.event_id = events.id)
+	FROM events WHERE id = ?`
+
+	row := m.DB.QueryRowContext(ctx, stmt, id)
 
 	evt := &models.Event{}
 
-	err := row.Scan(&evt.ID, &evt.Title, &evt.Desc, &evt.Time)
+	err = row.Scan(&evt.ID, &evt.UserID, &evt.Title, &evt.Desc, &evt.Time, &evt.TimeZone, &evt.Status, &evt.FinalSlotID, &evt.Hidden)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, models.ErrNoRecord
@@ -45,14 +80,195 @@ func (m *EventStore) Get(id int) (*models.Event, error) {
 		}
 	}
 
+	evt.Slots, err = m.slots(ctx, evt.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	return evt, nil
 }
 
-func (m *EventStore) Upcoming() ([]*models.Event, error) {
-	stmt := `SELECT id, title, description, time FROM events
-	WHERE time > UTC_TIMESTAMP() ORDER BY time DESC LIMIT 10`
+func (m *EventStore) Upcoming(ctx context.Context) (_ []*models.Event, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `SELECT id, COALESCE(user_id, 0), title, description, time, time_zone, status, COALESCE(final_slot_id, 0)
+	FROM events WHERE time > UTC_TIMESTAMP() AND id NOT IN (SELECT event_id FROM hidden_events)
+	ORDER BY time, id LIMIT 10`
+
+	rows, err := m.DB.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*models.Event{}
+
+	for rows.Next() {
+		evt := &models.Event{}
+
+		err = rows.Scan(&evt.ID, &evt.UserID, &evt.Title, &evt.Desc, &evt.Time, &evt.TimeZone, &evt.Status, &evt.FinalSlotID)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, evt)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// List returns a page of upcoming or past events matching the filter. One more
+// event than the limit is queried to know whether a next page exists.
+func (m *EventStore) List(ctx context.Context, f models.EventFilter) (_ *models.EventPage, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	if f.Limit < 1 {
+		f.Limit = models.DefaultLimit
+	}
+
+	where := []string{"time > UTC_TIMESTAMP()"}
+	if f.Past {
+		where = []string{"time <= UTC_TIMESTAMP()"}
+	}
+	args := []interface{}{}
+
+	if !f.From.IsZero() {
+		where = append(where, "time >= ?")
+		args = append(args, f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		where = append(where, "time < ?")
+		args = append(args, f.To.UTC())
+	}
+	if f.UserID > 0 {
+		where = append(where, "user_id = ?")
+		args = append(args, f.UserID)
+	}
+	if f.Title != "" {
+		where = append(where, "LOWER(title) LIKE ? ESCAPE '!'")
+		args = append(args, models.ContainsPattern(f.Title))
+	}
+	if !f.Hidden {
+		where = append(where, "id NOT IN (SELECT event_id FROM hidden_events)")
+	}
+
+	order, cmp := "ASC", ">"
+	if f.Desc {
+		order, cmp = "DESC", "<"
+	}
+
+	if f.After != nil {
+		where = append(where, fmt.Sprintf("(time %[1]s ? OR (time = ? AND id %[1]s ?))", cmp))
+		args = append(args, f.After.Time.UTC(), f.After.Time.UTC(), f.After.ID)
+	}
+
+	stmt := fmt.Sprintf(`SELECT id, COALESCE(user_id, 0), title, description, time, time_zone, status, COALESCE(final_slot_id, 0),
+	EXISTS (SELECT 1 FROM hidden_events h WHERE h.event_id = events.id)
+	FROM events WHERE %s ORDER BY time %s, id %s LIMIT ?`, strings.Join(where, " AND "), order, order)
+	args = append(args, f.Limit+1)
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &models.EventPage{Events: []*models.Event{}}
+
+	for rows.Next() {
+		evt := &models.Event{}
+
+		err = rows.Scan(&evt.ID, &evt.UserID, &evt.Title, &evt.Desc, &evt.Time, &evt.TimeZone, &evt.Status, &evt.FinalSlotID, &evt.Hidden)
+		if err != nil {
+			return nil, err
+		}
+
+		page.Events = append(page.Events, evt)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Events) > f.Limit {
+		page.Events = page.Events[:f.Limit]
+		page.Next = models.CursorOf(page.Events[f.Limit-1])
+	}
+
+	return page, nil
+}
+
+// Search returns a page of upcoming events containing all the terms of the
+// query, ranked by relevance.
+func (m *EventStore) Search(ctx context.Context, query string, offset, limit int) (_ *models.SearchResults, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	results := &models.SearchResults{Events: []*models.Event{}}
+
+	terms := models.SearchTerms(query)
+	if len(terms) == 0 {
+		return results, nil
+	}
+
+	if limit < 1 {
+		limit = models.DefaultLimit
+	}
+
+	// the boolean mode requires every term, as the other backends do
+	against := "+" + strings.Join(terms, " +")
+
+	stmt := `SELECT id, COALESCE(user_id, 0), title, description, time, time_zone, status, COALESCE(final_slot_id, 0)
+	FROM events WHERE time > UTC_TIMESTAMP() AND id NOT IN (SELECT event_id FROM hidden_events)
+	AND MATCH(title, description) AGAINST(? IN BOOLEAN MODE)
+	ORDER BY MATCH(title, description) AGAINST(? IN BOOLEAN MODE) DESC, time, id LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, against, against, limit+1, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		evt := &models.Event{}
+
+		err = rows.Scan(&evt.ID, &evt.UserID, &evt.Title, &evt.Desc, &evt.Time, &evt.TimeZone, &evt.Status, &evt.FinalSlotID)
+		if err != nil {
+			return nil, err
+		}
+
+		results.Events = append(results.Events, evt)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(results.Events) > limit {
+		results.Events, results.More = results.Events[:limit], true
+	}
+
+	return results, nil
+}
+
+// ForUser returns all events created by the user or on which they voted,
+// along with their slots, ordered by time. Hidden events are left out.
+func (m *EventStore) ForUser(ctx context.Context, userID int) (_ []*models.Event, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
 
-	rows, err := m.DB.Query(stmt)
+	stmt := `SELECT DISTINCT e.id, COALESCE(e.user_id, 0), e.title, e.description, e.time, e.time_zone, e.status, COALESCE(e.final_slot_id, 0)
+	FROM events e LEFT JOIN participants p ON p.event_id = e.id
+	WHERE (e.user_id = ? OR p.user_id = ?)
+	AND e.id NOT IN (SELECT event_id FROM hidden_events) ORDER BY e.time`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -63,7 +279,7 @@ func (m *EventStore) Upcoming() ([]*models.Event, error) {
 	for rows.Next() {
 		evt := &models.Event{}
 
-		err = rows.Scan(&evt.ID, &evt.Title, &evt.Desc, &evt.Time)
+		err = rows.Scan(&evt.ID, &evt.UserID, &evt.Title, &evt.Desc, &evt.Time, &evt.TimeZone, &evt.Status, &evt.FinalSlotID)
 		if err != nil {
 			return nil, err
 		}
@@ -75,5 +291,130 @@ func (m *EventStore) Upcoming() ([]*models.Event, error) {
 		return nil, err
 	}
 
+	for _, evt := range events {
+		evt.Slots, err = m.slots(ctx, evt.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return events, nil
 }
+
+// Update changes the title and the description of an event.
+func (m *EventStore) Update(ctx context.Context, id int, title, desc string) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `UPDATE events SET title = ?, description = ? WHERE id = ?`
+	_, err = m.DB.ExecContext(ctx, stmt, title, desc, id)
+	return err
+}
+
+// Delete removes an event along with its slots and votes.
+func (m *EventStore) Delete(ctx context.Context, id int) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `DELETE FROM events WHERE id = ?`
+	_, err = m.DB.ExecContext(ctx, stmt, id)
+	return err
+}
+
+// Purge removes the events that happened before the given time, along
+// with their slots and votes. It returns the number of events removed.
+func (m *EventStore) Purge(ctx context.Context, before time.Time) (_ int, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `DELETE FROM events WHERE time < ?`
+	result, err := m.DB.ExecContext(ctx, stmt, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+// Close marks the poll of an event as decided on the given slot.
+func (m *EventStore) Close(ctx context.Context, id, slotID int) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `UPDATE events SET status = ?, final_slot_id = ? WHERE id = ?`
+	_, err = m.DB.ExecContext(ctx, stmt, models.StatusClosed, slotID, id)
+	return err
+}
+
+// Cancel marks an event as cancelled.
+func (m *EventStore) Cancel(ctx context.Context, id int) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `UPDATE events SET status = ?, final_slot_id = NULL WHERE id = ?`
+	_, err = m.DB.ExecContext(ctx, stmt, models.StatusCancelled, id)
+	return err
+}
+
+// Reopen allows participants to vote again on a closed or cancelled event.
+func (m *EventStore) Reopen(ctx context.Context, id int) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `UPDATE events SET status = ?, final_slot_id = NULL WHERE id = ?`
+	_, err = m.DB.ExecContext(ctx, stmt, models.StatusOpen, id)
+	return err
+}
+
+// SetHidden hides an event from everyone but administrators, or shows it again.
+func (m *EventStore) SetHidden(ctx context.Context, id int, hidden bool) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	if !hidden {
+		stmt := `DELETE FROM hidden_events WHERE event_id = ?`
+		_, err = m.DB.ExecContext(ctx, stmt, id)
+		return err
+	}
+
+	stmt := `INSERT INTO hidden_events (event_id, hidden) VALUES (?, UTC_TIMESTAMP())
+	ON DUPLICATE KEY UPDATE event_id = event_id`
+	_, err = m.DB.ExecContext(ctx, stmt, id)
+	return err
+}
+
+// slots returns the candidate slots of an event, ordered chronologically.
+func (m *EventStore) slots(ctx context.Context, eventID int) ([]*models.Slot, error) {
+	stmt := `SELECT id, start_time, end_time FROM slots
+	WHERE event_id = ? ORDER BY start_time, end_time`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	slots := []*models.Slot{}
+
+	for rows.Next() {
+		s := &models.Slot{}
+
+		err = rows.Scan(&s.ID, &s.Start, &s.End)
+		if err != nil {
+			return nil, err
+		}
+
+		slots = append(slots, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return slots, nil
+}
@@ -0,0 +1,251 @@
+package mysql
+
+import "github.com/lobre/doodle/pkg/migrate"
+
+// Migrations holds the changes of the MySQL schema. Once released, a
+// migration must never be edited: add a new one instead.
+var Migrations = []migrate.Migration{
+	{
+		Version: 1,
+		Name:    "create_tables",
+		// The tables of the first release, which may already exist.
+		Up: `
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    hashed_password CHAR(60) NOT NULL,
+    created DATETIME NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    CONSTRAINT users_uc_email UNIQUE (email)
+);
+
+CREATE TABLE IF NOT EXISTS events (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    title VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL,
+    time DATETIME NOT NULL,
+    INDEX idx_events_time (time)
+);
+`,
+		Down: `
+DROP TABLE events;
+DROP TABLE users;
+`,
+	},
+	{
+		Version: 2,
+		Name:    "add_event_status",
+		Up: `
+ALTER TABLE events ADD COLUMN status ENUM('open', 'closed', 'cancelled') NOT NULL DEFAULT 'open';
+`,
+		Down: `
+ALTER TABLE events DROP COLUMN status;
+`,
+	},
+	{
+		Version: 3,
+		Name:    "add_time_zones",
+		Up: `
+ALTER TABLE users ADD COLUMN time_zone VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN time_zone VARCHAR(64) NOT NULL DEFAULT '';
+`,
+		Down: `
+ALTER TABLE events DROP COLUMN time_zone;
+ALTER TABLE users DROP COLUMN time_zone;
+`,
+	},
+	{
+		Version: 4,
+		Name:    "add_feed_token",
+		Up: `
+ALTER TABLE users ADD COLUMN feed_token CHAR(43) NULL,
+    ADD CONSTRAINT users_uc_feed_token UNIQUE (feed_token);
+`,
+		Down: `
+ALTER TABLE users DROP COLUMN feed_token;
+`,
+	},
+	{
+		Version: 5,
+		Name:    "add_event_owner",
+		// Events of the first release have no owner.
+		Up: `
+ALTER TABLE events ADD COLUMN user_id INTEGER NULL,
+    ADD CONSTRAINT events_fk_user FOREIGN KEY (user_id) REFERENCES users(id);
+`,
+		Down: `
+ALTER TABLE events DROP FOREIGN KEY events_fk_user;
+ALTER TABLE events DROP COLUMN user_id;
+`,
+	},
+	{
+		Version: 6,
+		Name:    "create_slots",
+		Up: `
+CREATE TABLE slots (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    event_id INTEGER NOT NULL,
+    start_time DATETIME NOT NULL,
+    end_time DATETIME NOT NULL,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_slots_event_id ON slots(event_id, start_time);
+
+ALTER TABLE events ADD COLUMN final_slot_id INTEGER NULL,
+    ADD CONSTRAINT events_fk_final_slot FOREIGN KEY (final_slot_id) REFERENCES slots(id) ON DELETE SET NULL;
+`,
+		Down: `
+ALTER TABLE events DROP FOREIGN KEY events_fk_final_slot;
+ALTER TABLE events DROP COLUMN final_slot_id;
+DROP TABLE slots;
+`,
+	},
+	{
+		Version: 7,
+		Name:    "create_votes",
+		Up: `
+CREATE TABLE participants (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    event_id INTEGER NOT NULL,
+    user_id INTEGER NULL,
+    name VARCHAR(255) NOT NULL,
+    updated DATETIME NOT NULL,
+    CONSTRAINT participants_uc_event_name UNIQUE (event_id, name),
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE TABLE votes (
+    participant_id INTEGER NOT NULL,
+    slot_id INTEGER NOT NULL,
+    answer ENUM('yes', 'ifneedbe', 'no') NOT NULL,
+    PRIMARY KEY (participant_id, slot_id),
+    FOREIGN KEY (participant_id) REFERENCES participants(id) ON DELETE CASCADE,
+    FOREIGN KEY (slot_id) REFERENCES slots(id) ON DELETE CASCADE
+);
+`,
+		Down: `
+DROP TABLE votes;
+DROP TABLE participants;
+`,
+	},
+	{
+		Version: 8,
+		Name:    "create_tokens",
+		Up: `
+CREATE TABLE tokens (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    hashed_token CHAR(64) NOT NULL,
+    created DATETIME NOT NULL,
+    last_used DATETIME NULL,
+    CONSTRAINT tokens_uc_hashed_token UNIQUE (hashed_token),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+`,
+		Down: `
+DROP TABLE tokens;
+`,
+	},
+	{
+		Version: 9,
+		Name:    "search_events",
+		Up: `
+ALTER TABLE events ADD FULLTEXT INDEX events_ft_search (title, description);
+`,
+		Down: `
+ALTER TABLE events DROP INDEX events_ft_search;
+`,
+	},
+	{
+		Version: 10,
+		Name:    "create_password_resets",
+		Up: `
+CREATE TABLE password_resets (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    hashed_token CHAR(64) NOT NULL,
+    expires DATETIME NOT NULL,
+    CONSTRAINT password_resets_uc_hashed_token UNIQUE (hashed_token),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+`,
+		Down: `
+DROP TABLE password_resets;
+`,
+	},
+	{
+		Version: 11,
+		Name:    "create_email_verifications",
+		// Users already registered are considered verified.
+		Up: `
+CREATE TABLE email_verifications (
+    user_id INTEGER NOT NULL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    hashed_token CHAR(64) NULL,
+    sent DATETIME NOT NULL,
+    expires DATETIME NULL,
+    verified DATETIME NULL,
+    CONSTRAINT email_verifications_uc_hashed_token UNIQUE (hashed_token),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO email_verifications (user_id, email, sent, verified)
+SELECT id, email, created, created FROM users;
+`,
+		Down: `
+DROP TABLE email_verifications;
+`,
+	},
+	{
+		Version: 12,
+		Name:    "create_admin",
+		// Users without a role are regular ones.
+		Up: `
+CREATE TABLE user_roles (
+    user_id INTEGER NOT NULL PRIMARY KEY,
+    role ENUM('admin') NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE hidden_events (
+    event_id INTEGER NOT NULL PRIMARY KEY,
+    hidden DATETIME NOT NULL,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
+);
+
+CREATE TABLE audit_log (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    admin_id INTEGER NULL,
+    action VARCHAR(32) NOT NULL,
+    target_id INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    FOREIGN KEY (admin_id) REFERENCES users(id) ON DELETE SET NULL
+);
+`,
+		Down: `
+DROP TABLE audit_log;
+DROP TABLE hidden_events;
+DROP TABLE user_roles;
+`,
+	},
+	{
+		Version: 13,
+		Name:    "create_user_sessions",
+		// The version is raised when the password changes, which ends the
+		// sessions started before. Users without one are at version 0.
+		Up: `
+CREATE TABLE user_sessions (
+    user_id INTEGER NOT NULL PRIMARY KEY,
+    version INTEGER NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+`,
+		Down: `
+DROP TABLE user_sessions;
+`,
+	},
+}
@@ -0,0 +1,81 @@
+package mysql
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/lobre/doodle/pkg/models/storetest"
+)
+
+// The suite runs against a real database only when DOODLE_TEST_MYSQL_DSN
+// is set, such as "web:pass@/doodle_test?parseTime=true". All the data
+// of that database is dropped.
+func TestStores(t *testing.T) {
+	dsn := os.Getenv("DOODLE_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("DOODLE_TEST_MYSQL_DSN is not set")
+	}
+
+	storetest.Run(t, func(t *testing.T) *storetest.Stores {
+		db, err := sql.Open("mysql", dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		storetest.Migrate(t, db, Migrations)
+
+		return stores(db)
+	})
+}
+
+func TestUpgrade(t *testing.T) {
+	dsn := os.Getenv("DOODLE_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("DOODLE_TEST_MYSQL_DSN is not set")
+	}
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	storetest.Upgrade(t, db, baseline, Migrations, stores(db))
+}
+
+func stores(db *sql.DB) *storetest.Stores {
+	return &storetest.Stores{
+		Events: &EventStore{DB: db},
+		Votes:  &VoteStore{DB: db},
+		Users:  &UserStore{DB: db},
+		Tokens: &TokenStore{DB: db},
+		Resets: &ResetStore{DB: db},
+		Verifs: &VerificationStore{DB: db},
+		Audit:  &AuditStore{DB: db},
+	}
+}
+
+// baseline is the schema of the first release, before migrations existed.
+const baseline = `
+CREATE TABLE events (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    title VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL,
+    time DATETIME NOT NULL
+);
+
+CREATE INDEX idx_events_time ON events(time);
+
+CREATE TABLE users (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    hashed_password CHAR(60) NOT NULL,
+    created DATETIME NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
+`
@@ -0,0 +1,114 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lobre/doodle/pkg/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type ResetStore struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+var _ models.ResetStore = (*ResetStore)(nil)
+
+// Insert saves a token allowing the active user with the given email
+// to reset their password, until it expires.
+func (m *ResetStore) Insert(ctx context.Context, email, token string, expires time.Time) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `INSERT INTO password_resets (user_id, hashed_token, expires)
+	SELECT id, ?, ? FROM users WHERE email = ? AND active = TRUE`
+
+	result, err := m.DB.ExecContext(ctx, stmt, models.HashToken(token), expires.UTC(), email)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+// Check verifies that a token can still be used, without using it.
+func (m *ResetStore) Check(ctx context.Context, token string) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `SELECT r.user_id FROM password_resets r JOIN users u ON u.id = r.user_id
+	WHERE r.hashed_token = ? AND r.expires > UTC_TIMESTAMP() AND u.active = TRUE`
+
+	var userID int
+	err = m.DB.QueryRowContext(ctx, stmt, models.HashToken(token)).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ErrInvalidCredentials
+	}
+	return err
+}
+
+// Reset changes the password of the user owning the token. All the tokens
+// of the user are dropped, so that none of them can be used again. Their
+// sessions and API tokens are ended as well.
+func (m *ResetStore) Reset(ctx context.Context, token, password string) (err error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// the lock prevents the token from being used twice concurrently
+	stmt := `SELECT r.user_id FROM password_resets r JOIN users u ON u.id = r.user_id
+	WHERE r.hashed_token = ? AND r.expires > UTC_TIMESTAMP() AND u.active = TRUE FOR UPDATE`
+
+	var userID int
+	err = tx.QueryRowContext(ctx, stmt, models.HashToken(token)).Scan(&userID)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrInvalidCredentials
+		}
+		return err
+	}
+
+	stmt = `UPDATE users SET hashed_password = ? WHERE id = ?`
+	_, err = tx.ExecContext(ctx, stmt, string(hashedPassword), userID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	stmt = `DELETE FROM password_resets WHERE user_id = ?`
+	_, err = tx.ExecContext(ctx, stmt, userID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = endSessions(ctx, tx, userID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,129 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lobre/doodle/pkg/models"
+)
+
+type VoteStore struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+var _ models.VoteStore = (*VoteStore)(nil)
+
+// Upsert saves the answers of a participant. Participants are identified
+// by their name within an event, so that voting again under the same name
+// replaces the previous answers. The userID is optional and can be 0. The
+// answers given by a user under a name can only be replaced by that user,
+// and a participant never changes hands.
+func (m *VoteStore) Upsert(ctx context.Context, eventID, userID int, name string, answers map[int]models.Answer) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var uid sql.NullInt64
+	if userID > 0 {
+		uid = sql.NullInt64{Int64: int64(userID), Valid: true}
+	}
+
+	// LAST_INSERT_ID(id) makes LastInsertId return the existing row
+	// when the participant already voted.
+	stmt := `INSERT INTO participants (event_id, user_id, name, updated)
+	VALUES (?, ?, ?, UTC_TIMESTAMP())
+	ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id), updated = VALUES(updated)`
+
+	result, err := tx.ExecContext(ctx, stmt, eventID, uid, name)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var owner sql.NullInt64
+	stmt = `SELECT user_id FROM participants WHERE id = ?`
+	err = tx.QueryRowContext(ctx, stmt, id).Scan(&owner)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if owner.Valid && int(owner.Int64) != userID {
+		tx.Rollback()
+		return models.ErrNameTaken
+	}
+
+	stmt = `INSERT INTO votes (participant_id, slot_id, answer) VALUES (?, ?, ?)
+	ON DUPLICATE KEY UPDATE answer = VALUES(answer)`
+
+	for slotID, answer := range answers {
+		_, err = tx.ExecContext(ctx, stmt, id, slotID, string(answer))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ForEvent returns the participants of an event with their answers,
+// in the order they first voted.
+func (m *VoteStore) ForEvent(ctx context.Context, eventID int) (_ []*models.Participant, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `SELECT p.id, p.event_id, COALESCE(p.user_id, 0), p.name, p.updated, v.slot_id, v.answer
+	FROM participants p LEFT JOIN votes v ON v.participant_id = p.id
+	WHERE p.event_id = ? ORDER BY p.id`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	participants := []*models.Participant{}
+
+	var p *models.Participant
+	for rows.Next() {
+		cur := &models.Participant{Answers: map[int]models.Answer{}}
+
+		var slotID sql.NullInt64
+		var answer sql.NullString
+
+		err = rows.Scan(&cur.ID, &cur.EventID, &cur.UserID, &cur.Name, &cur.Updated, &slotID, &answer)
+		if err != nil {
+			return nil, err
+		}
+
+		// rows are grouped by participant, so only start
+		// a new one when the ID changes
+		if p == nil || p.ID != cur.ID {
+			p = cur
+			participants = append(participants, p)
+		}
+
+		if slotID.Valid && answer.Valid {
+			p.Answers[int(slotID.Int64)] = models.Answer(answer.String)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return participants, nil
+}
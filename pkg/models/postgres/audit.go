@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lobre/doodle/pkg/models"
+)
+
+type AuditStore struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+var _ models.AuditStore = (*AuditStore)(nil)
+
+func (m *AuditStore) Insert(ctx context.Context, adminID int, action models.Action, targetID int) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `INSERT INTO audit_log (admin_id, action, target_id, created)
+	VALUES (NULLIF($1, 0), $2, $3, NOW())`
+
+	_, err = m.DB.ExecContext(ctx, stmt, adminID, action, targetID)
+	return err
+}
+
+// List returns a page of the audit trail, the latest entries first,
+// starting before the entry of the given ID, if any. One more entry
+// than the limit is queried to know whether a next page exists.
+func (m *AuditStore) List(ctx context.Context, before, limit int) (_ *models.AuditPage, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	if limit < 1 {
+		limit = models.DefaultLimit
+	}
+
+	stmt := `SELECT a.id, COALESCE(a.admin_id, 0), COALESCE(u.name, ''), a.action, a.target_id, a.created
+	FROM audit_log a LEFT JOIN users u ON u.id = a.admin_id
+	WHERE $1 < 1 OR a.id < $1
+	ORDER BY a.id DESC LIMIT $2`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, before, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &models.AuditPage{Entries: []*models.AuditEntry{}}
+
+	for rows.Next() {
+		e := &models.AuditEntry{}
+
+		err = rows.Scan(&e.ID, &e.AdminID, &e.AdminName, &e.Action, &e.TargetID, &e.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		page.Entries = append(page.Entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Entries) > limit {
+		page.Entries = page.Entries[:limit]
+		page.Next = page.Entries[limit-1].ID
+	}
+
+	return page, nil
+}
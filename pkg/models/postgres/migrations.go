@@ -0,0 +1,238 @@
+package postgres
+
+import "github.com/lobre/doodle/pkg/migrate"
+
+// Migrations holds the changes of the PostgreSQL schema. Once released, a
+// migration must never be edited: add a new one instead.
+var Migrations = []migrate.Migration{
+	{
+		Version: 1,
+		Name:    "create_tables",
+		// The tables of the first release, which may already exist.
+		Up: `
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL NOT NULL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    hashed_password CHAR(60) NOT NULL,
+    created TIMESTAMPTZ NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    CONSTRAINT users_uc_email UNIQUE (email)
+);
+
+CREATE TABLE IF NOT EXISTS events (
+    id SERIAL NOT NULL PRIMARY KEY,
+    title VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL,
+    time TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_events_time ON events(time);
+`,
+		Down: `
+DROP TABLE events;
+DROP TABLE users;
+`,
+	},
+	{
+		Version: 2,
+		Name:    "add_event_status",
+		Up: `
+ALTER TABLE events ADD COLUMN status VARCHAR(9) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'closed', 'cancelled'));
+`,
+		Down: `
+ALTER TABLE events DROP COLUMN status;
+`,
+	},
+	{
+		Version: 3,
+		Name:    "add_time_zones",
+		Up: `
+ALTER TABLE users ADD COLUMN time_zone VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN time_zone VARCHAR(64) NOT NULL DEFAULT '';
+`,
+		Down: `
+ALTER TABLE events DROP COLUMN time_zone;
+ALTER TABLE users DROP COLUMN time_zone;
+`,
+	},
+	{
+		Version: 4,
+		Name:    "add_feed_token",
+		Up: `
+ALTER TABLE users ADD COLUMN feed_token CHAR(43) NULL,
+    ADD CONSTRAINT users_uc_feed_token UNIQUE (feed_token);
+`,
+		Down: `
+ALTER TABLE users DROP COLUMN feed_token;
+`,
+	},
+	{
+		Version: 5,
+		Name:    "add_event_owner",
+		// Events of the first release have no owner.
+		Up: `
+ALTER TABLE events ADD COLUMN user_id INTEGER NULL REFERENCES users(id);
+`,
+		Down: `
+ALTER TABLE events DROP COLUMN user_id;
+`,
+	},
+	{
+		Version: 6,
+		Name:    "create_slots",
+		Up: `
+CREATE TABLE slots (
+    id SERIAL NOT NULL PRIMARY KEY,
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    start_time TIMESTAMPTZ NOT NULL,
+    end_time TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_slots_event_id ON slots(event_id, start_time);
+
+ALTER TABLE events ADD COLUMN final_slot_id INTEGER NULL REFERENCES slots(id) ON DELETE SET NULL;
+`,
+		Down: `
+ALTER TABLE events DROP COLUMN final_slot_id;
+DROP TABLE slots;
+`,
+	},
+	{
+		Version: 7,
+		Name:    "create_votes",
+		Up: `
+CREATE TABLE participants (
+    id SERIAL NOT NULL PRIMARY KEY,
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    user_id INTEGER NULL REFERENCES users(id) ON DELETE SET NULL,
+    name VARCHAR(255) NOT NULL,
+    updated TIMESTAMPTZ NOT NULL,
+    CONSTRAINT participants_uc_event_name UNIQUE (event_id, name)
+);
+
+CREATE TABLE votes (
+    participant_id INTEGER NOT NULL REFERENCES participants(id) ON DELETE CASCADE,
+    slot_id INTEGER NOT NULL REFERENCES slots(id) ON DELETE CASCADE,
+    answer VARCHAR(8) NOT NULL CHECK (answer IN ('yes', 'ifneedbe', 'no')),
+    PRIMARY KEY (participant_id, slot_id)
+);
+`,
+		Down: `
+DROP TABLE votes;
+DROP TABLE participants;
+`,
+	},
+	{
+		Version: 8,
+		Name:    "create_tokens",
+		Up: `
+CREATE TABLE tokens (
+    id SERIAL NOT NULL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    hashed_token CHAR(64) NOT NULL,
+    created TIMESTAMPTZ NOT NULL,
+    last_used TIMESTAMPTZ NULL,
+    CONSTRAINT tokens_uc_hashed_token UNIQUE (hashed_token)
+);
+`,
+		Down: `
+DROP TABLE tokens;
+`,
+	},
+	{
+		Version: 9,
+		Name:    "search_events",
+		Up: `
+CREATE INDEX idx_events_search ON events USING GIN ((
+    setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', description), 'B')
+));
+`,
+		Down: `
+DROP INDEX idx_events_search;
+`,
+	},
+	{
+		Version: 10,
+		Name:    "create_password_resets",
+		Up: `
+CREATE TABLE password_resets (
+    id SERIAL NOT NULL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    hashed_token CHAR(64) NOT NULL,
+    expires TIMESTAMPTZ NOT NULL,
+    CONSTRAINT password_resets_uc_hashed_token UNIQUE (hashed_token)
+);
+`,
+		Down: `
+DROP TABLE password_resets;
+`,
+	},
+	{
+		Version: 11,
+		Name:    "create_email_verifications",
+		// Users already registered are considered verified.
+		Up: `
+CREATE TABLE email_verifications (
+    user_id INTEGER NOT NULL PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    hashed_token CHAR(64) NULL,
+    sent TIMESTAMPTZ NOT NULL,
+    expires TIMESTAMPTZ NULL,
+    verified TIMESTAMPTZ NULL,
+    CONSTRAINT email_verifications_uc_hashed_token UNIQUE (hashed_token)
+);
+
+INSERT INTO email_verifications (user_id, email, sent, verified)
+SELECT id, email, created, created FROM users;
+`,
+		Down: `
+DROP TABLE email_verifications;
+`,
+	},
+	{
+		Version: 12,
+		Name:    "create_admin",
+		// Users without a role are regular ones.
+		Up: `
+CREATE TABLE user_roles (
+    user_id INTEGER NOT NULL PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(16) NOT NULL CHECK (role IN ('admin'))
+);
+
+CREATE TABLE hidden_events (
+    event_id INTEGER NOT NULL PRIMARY KEY REFERENCES events(id) ON DELETE CASCADE,
+    hidden TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE audit_log (
+    id SERIAL NOT NULL PRIMARY KEY,
+    admin_id INTEGER NULL REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(32) NOT NULL,
+    target_id INTEGER NOT NULL,
+    created TIMESTAMPTZ NOT NULL
+);
+`,
+		Down: `
+DROP TABLE audit_log;
+DROP TABLE hidden_events;
+DROP TABLE user_roles;
+`,
+	},
+	{
+		Version: 13,
+		Name:    "create_user_sessions",
+		// The version is raised when the password changes, which ends the
+		// sessions started before. Users without one are at version 0.
+		Up: `
+CREATE TABLE user_sessions (
+    user_id INTEGER NOT NULL PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL
+);
+`,
+		Down: `
+DROP TABLE user_sessions;
+`,
+	},
+}
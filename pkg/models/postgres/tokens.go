@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lobre/doodle/pkg/models"
+)
+
+type TokenStore struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+var _ models.TokenStore = (*TokenStore)(nil)
+
+func (m *TokenStore) Insert(ctx context.Context, userID int, name, token string) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `INSERT INTO tokens (user_id, name, hashed_token, created)
+	VALUES ($1, $2, $3, NOW())`
+
+	_, err = m.DB.ExecContext(ctx, stmt, userID, name, models.HashToken(token))
+	return err
+}
+
+func (m *TokenStore) ForUser(ctx context.Context, userID int) (_ []*models.Token, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `SELECT id, user_id, name, created, last_used
+	FROM tokens WHERE user_id = $1 ORDER BY created DESC`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*models.Token{}
+
+	for rows.Next() {
+		t := &models.Token{}
+		var lastUsed sql.NullTime
+		err = rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Created, &lastUsed)
+		if err != nil {
+			return nil, err
+		}
+		t.LastUsed = lastUsed.Time
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Delete revokes a token. The user is given so that users cannot
+// revoke the tokens of someone else.
+func (m *TokenStore) Delete(ctx context.Context, id, userID int) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `DELETE FROM tokens WHERE id = $1 AND user_id = $2`
+
+	result, err := m.DB.ExecContext(ctx, stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+// Authenticate returns the ID of the user owning the given token,
+// and records when it has been used for the last time.
+func (m *TokenStore) Authenticate(ctx context.Context, token string) (_ int, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	var id, userID int
+
+	stmt := `SELECT id, user_id FROM tokens WHERE hashed_token = $1`
+	row := m.DB.QueryRowContext(ctx, stmt, models.HashToken(token))
+	err = row.Scan(&id, &userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrInvalidCredentials
+		} else {
+			return 0, err
+		}
+	}
+
+	stmt = `UPDATE tokens SET last_used = NOW() WHERE id = $1`
+	_, err = m.DB.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,495 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/lobre/doodle/pkg/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type UserStore struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+var _ models.UserStore = (*UserStore)(nil)
+
+// userColumns are the columns of a user, in the order of the fields of
+// models.User. The address is verified as long as it is the one the token
+// was sent to, users without a role are regular ones, and sessions are at
+// version 0 until the password changes.
+const userColumns = `id, name, email, created, active, time_zone, COALESCE(feed_token, ''),
+	EXISTS (SELECT 1 FROM email_verifications v
+		WHERE v.user_id = users.id AND v.email = users.email AND v.verified IS NOT NULL),
+	COALESCE((SELECT role FROM user_roles r WHERE r.user_id = users.id), 'user'),
+	COALESCE((SELECT version FROM user_sessions s WHERE s.user_id = users.id), 0)`
+
+func (m *UserStore) Insert(ctx context.Context, name, email, password string) (err error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	// hashing is slow on purpose, so it is not part of the query deadline
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `INSERT INTO users (name, email, hashed_password, created)
+	VALUES($1, $2, $3, NOW())`
+
+	_, err = m.DB.ExecContext(ctx, stmt, name, email, string(hashedPassword))
+	if err != nil {
+		if isDuplicateEmail(err) {
+			return models.ErrDuplicateEmail
+		}
+		return err
+	}
+	return nil
+}
+
+func (m *UserStore) Authenticate(ctx context.Context, email, password string) (_ int, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	var id int
+	var hashedPassword []byte
+
+	stmt := `SELECT id, hashed_password FROM users WHERE email = $1 AND active = TRUE`
+
+	row := m.DB.QueryRowContext(ctx, stmt, email)
+	err = row.Scan(&id, &hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrInvalidCredentials
+		} else {
+			return 0, err
+		}
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return 0, models.ErrInvalidCredentials
+		} else {
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
+func (m *UserStore) Get(ctx context.Context, id int) (_ *models.User, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	u := &models.User{}
+
+	stmt := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+	row := m.DB.QueryRowContext(ctx, stmt, id)
+	err = row.Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Active, &u.TimeZone, &u.FeedToken, &u.Verified, &u.Role, &u.SessionVersion)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+	return u, nil
+}
+
+func (m *UserStore) GetByEmail(ctx context.Context, email string) (_ *models.User, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	var id int
+
+	stmt := `SELECT id FROM users WHERE email = $1`
+	err = m.DB.QueryRowContext(ctx, stmt, email).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return m.Get(ctx, id)
+}
+
+// List returns a page of users matching the filter, sorted by ID. One more
+// user than the limit is queried to know whether a next page exists.
+func (m *UserStore) List(ctx context.Context, f models.UserFilter) (_ *models.UserPage, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	if f.Limit < 1 {
+		f.Limit = models.DefaultLimit
+	}
+
+	where := []string{}
+	args := []interface{}{}
+
+	// arg adds a value to the arguments and returns its placeholder
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	where = append(where, "id > "+arg(f.After))
+	if f.Query != "" {
+		pattern := arg(models.ContainsPattern(f.Query))
+		where = append(where, fmt.Sprintf("(LOWER(name) LIKE %[1]s ESCAPE '!' OR LOWER(email) LIKE %[1]s ESCAPE '!')", pattern))
+	}
+
+	stmt := fmt.Sprintf(`SELECT %s FROM users WHERE %s ORDER BY id LIMIT %s`, userColumns, strings.Join(where, " AND "), arg(f.Limit+1))
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &models.UserPage{Users: []*models.User{}}
+
+	for rows.Next() {
+		u := &models.User{}
+
+		err = rows.Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Active, &u.TimeZone, &u.FeedToken, &u.Verified, &u.Role, &u.SessionVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		page.Users = append(page.Users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Users) > f.Limit {
+		page.Users = page.Users[:f.Limit]
+		page.Next = page.Users[f.Limit-1].ID
+	}
+
+	return page, nil
+}
+
+func (m *UserStore) SetTimeZone(ctx context.Context, id int, tz string) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `UPDATE users SET time_zone = $1 WHERE id = $2`
+	_, err = m.DB.ExecContext(ctx, stmt, tz, id)
+	return err
+}
+
+func (m *UserStore) SetFeedToken(ctx context.Context, id int, token string) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `UPDATE users SET feed_token = $1 WHERE id = $2`
+	_, err = m.DB.ExecContext(ctx, stmt, token, id)
+	return err
+}
+
+// GetByFeedToken returns the active user owning the given calendar feed token.
+func (m *UserStore) GetByFeedToken(ctx context.Context, token string) (_ *models.User, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	var id int
+
+	stmt := `SELECT id FROM users WHERE feed_token = $1 AND active = TRUE`
+	row := m.DB.QueryRowContext(ctx, stmt, token)
+	err = row.Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return m.Get(ctx, id)
+}
+
+func (m *UserStore) SetName(ctx context.Context, id int, name string) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `UPDATE users SET name = $1 WHERE id = $2`
+	_, err = m.DB.ExecContext(ctx, stmt, name, id)
+	return err
+}
+
+// SetEmail changes the address of the user, which is unverified until
+// a token sent to the new address comes back.
+func (m *UserStore) SetEmail(ctx context.Context, id int, email string) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `UPDATE users SET email = $1 WHERE id = $2`
+	_, err = m.DB.ExecContext(ctx, stmt, email, id)
+	if err != nil {
+		if isDuplicateEmail(err) {
+			return models.ErrDuplicateEmail
+		}
+		return err
+	}
+	return nil
+}
+
+// ChangePassword replaces the password of the active user, provided the
+// current one is given. Pending password resets are dropped along, and
+// the sessions and API tokens of the user are ended.
+func (m *UserStore) ChangePassword(ctx context.Context, id int, current, password string) (err error) {
+	hashedPassword, err := m.hashedPassword(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(current))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return models.ErrInvalidCredentials
+		} else {
+			return err
+		}
+	}
+
+	hashedPassword, err = bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	stmt := `UPDATE users SET hashed_password = $1 WHERE id = $2`
+	_, err = tx.ExecContext(ctx, stmt, string(hashedPassword), id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	stmt = `DELETE FROM password_resets WHERE user_id = $1`
+	_, err = tx.ExecContext(ctx, stmt, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = endSessions(ctx, tx, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Activate enables the account of the user again.
+func (m *UserStore) Activate(ctx context.Context, id int) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `UPDATE users SET active = TRUE WHERE id = $1`
+	_, err = m.DB.ExecContext(ctx, stmt, id)
+	return err
+}
+
+// Deactivate disables the account of the user, who cannot log in anymore.
+// Their data is kept.
+func (m *UserStore) Deactivate(ctx context.Context, id int) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = keepAdmin(ctx, tx, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	stmt := `UPDATE users SET active = FALSE WHERE id = $1`
+	_, err = tx.ExecContext(ctx, stmt, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetRole gives a role to the user. Only the roles other than the
+// default one are stored.
+func (m *UserStore) SetRole(ctx context.Context, id int, role models.Role) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	if role == models.RoleUser {
+		tx, err := m.DB.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+
+		err = keepAdmin(ctx, tx, id)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		stmt := `DELETE FROM user_roles WHERE user_id = $1`
+		_, err = tx.ExecContext(ctx, stmt, id)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		return tx.Commit()
+	}
+
+	stmt := `INSERT INTO user_roles (user_id, role) VALUES ($1, $2)
+	ON CONFLICT (user_id) DO UPDATE SET role = EXCLUDED.role`
+	_, err = m.DB.ExecContext(ctx, stmt, id, role)
+	return err
+}
+
+// Erase permanently deletes the user along with their events. Their votes
+// on the events of others are kept for the polls to stay accurate, but
+// under an anonymous name and no longer linked to them.
+func (m *UserStore) Erase(ctx context.Context, id int) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = keepAdmin(ctx, tx, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	stmt := `DELETE FROM events WHERE user_id = $1`
+	_, err = tx.ExecContext(ctx, stmt, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// the ID of the participant keeps the names unique within each event
+	stmt = `UPDATE participants SET user_id = NULL, name = $1 || ' #' || id WHERE user_id = $2`
+	_, err = tx.ExecContext(ctx, stmt, models.ErasedName, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	stmt = `DELETE FROM users WHERE id = $1`
+	_, err = tx.ExecContext(ctx, stmt, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// keepAdmin returns ErrLastAdmin if the user is the only active
+// administrator. The administrators stay locked until the end of tx, so
+// that two of them cannot be removed at once.
+func keepAdmin(ctx context.Context, tx *sql.Tx, id int) error {
+	stmt := `SELECT u.id FROM users u JOIN user_roles r ON r.user_id = u.id
+	WHERE r.role = $1 AND u.active = TRUE FOR UPDATE`
+	rows, err := tx.QueryContext(ctx, stmt, models.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	last := false
+	for rows.Next() {
+		var adminID int
+		err = rows.Scan(&adminID)
+		if err != nil {
+			return err
+		}
+
+		if adminID != id {
+			return nil
+		}
+		last = true
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if last {
+		return models.ErrLastAdmin
+	}
+
+	return nil
+}
+
+// endSessions raises the session version of the user, which ends the
+// sessions started before, and deletes their API tokens.
+func endSessions(ctx context.Context, tx *sql.Tx, id int) error {
+	stmt := `INSERT INTO user_sessions (user_id, version) VALUES ($1, 1)
+	ON CONFLICT (user_id) DO UPDATE SET version = user_sessions.version + 1`
+	_, err := tx.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	stmt = `DELETE FROM tokens WHERE user_id = $1`
+	_, err = tx.ExecContext(ctx, stmt, id)
+	return err
+}
+
+// hashedPassword returns the hash of the password of the active user,
+// or ErrInvalidCredentials if there is no such user.
+func (m *UserStore) hashedPassword(ctx context.Context, id int) (_ []byte, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	var hashedPassword []byte
+
+	stmt := `SELECT hashed_password FROM users WHERE id = $1 AND active = TRUE`
+	err = m.DB.QueryRowContext(ctx, stmt, id).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrInvalidCredentials
+		} else {
+			return nil, err
+		}
+	}
+
+	return hashedPassword, nil
+}
+
+// isDuplicateEmail reports whether err is due to an address already in use.
+func isDuplicateEmail(err error) bool {
+	var pqError *pq.Error
+	if errors.As(err, &pqError) {
+		return pqError.Code == "23505" && pqError.Constraint == "users_uc_email"
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lobre/doodle/pkg/models"
+)
+
+type VerificationStore struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+var _ models.VerificationStore = (*VerificationStore)(nil)
+
+// Insert saves a token confirming the current address of the active user
+// with the given email, in place of any previous one, until it expires.
+func (m *VerificationStore) Insert(ctx context.Context, email, token string, expires time.Time) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `INSERT INTO email_verifications (user_id, email, hashed_token, sent, expires, verified)
+	SELECT id, email, $1, NOW(), $2, NULL FROM users WHERE email = $3 AND active = TRUE
+	ON CONFLICT (user_id) DO UPDATE SET email = EXCLUDED.email, hashed_token = EXCLUDED.hashed_token,
+	sent = EXCLUDED.sent, expires = EXCLUDED.expires, verified = NULL`
+
+	result, err := m.DB.ExecContext(ctx, stmt, models.HashToken(token), expires.UTC(), email)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+// Sent returns when the last token was sent to the user.
+func (m *VerificationStore) Sent(ctx context.Context, userID int) (_ time.Time, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	var sent time.Time
+
+	stmt := `SELECT sent FROM email_verifications WHERE user_id = $1`
+	err = m.DB.QueryRowContext(ctx, stmt, userID).Scan(&sent)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, err
+	}
+
+	return sent, nil
+}
+
+// Verify marks the address the token was sent to as verified, if it is
+// still the address of the user, and returns the ID of the user.
+func (m *VerificationStore) Verify(ctx context.Context, token string) (_ int, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	hash := models.HashToken(token)
+
+	stmt := `SELECT v.user_id FROM email_verifications v JOIN users u ON u.id = v.user_id
+	WHERE v.hashed_token = $1 AND v.expires > NOW() AND v.email = u.email AND u.active = TRUE`
+
+	var userID int
+	err = m.DB.QueryRowContext(ctx, stmt, hash).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrInvalidCredentials
+		}
+		return 0, err
+	}
+
+	// the token is checked again, in case it has been used or replaced meanwhile
+	stmt = `UPDATE email_verifications SET hashed_token = NULL, expires = NULL, verified = NOW()
+	WHERE user_id = $1 AND hashed_token = $2`
+
+	result, err := m.DB.ExecContext(ctx, stmt, userID, hash)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if n == 0 {
+		return 0, models.ErrInvalidCredentials
+	}
+
+	return userID, nil
+}
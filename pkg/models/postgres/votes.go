@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lobre/doodle/pkg/models"
+)
+
+type VoteStore struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+var _ models.VoteStore = (*VoteStore)(nil)
+
+// Upsert saves the answers of a participant. Participants are identified
+// by their name within an event, so that voting again under the same name
+// replaces the previous answers. The userID is optional and can be 0. The
+// answers given by a user under a name can only be replaced by that user,
+// and a participant never changes hands.
+func (m *VoteStore) Upsert(ctx context.Context, eventID, userID int, name string, answers map[int]models.Answer) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var uid sql.NullInt64
+	if userID > 0 {
+		uid = sql.NullInt64{Int64: int64(userID), Valid: true}
+	}
+
+	// RETURNING gives the existing row when the participant already voted.
+	stmt := `INSERT INTO participants (event_id, user_id, name, updated)
+	VALUES ($1, $2, $3, NOW())
+	ON CONFLICT (event_id, name) DO UPDATE SET updated = EXCLUDED.updated
+	RETURNING id, user_id`
+
+	var id int
+	var owner sql.NullInt64
+	err = tx.QueryRowContext(ctx, stmt, eventID, uid, name).Scan(&id, &owner)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if owner.Valid && int(owner.Int64) != userID {
+		tx.Rollback()
+		return models.ErrNameTaken
+	}
+
+	stmt = `INSERT INTO votes (participant_id, slot_id, answer) VALUES ($1, $2, $3)
+	ON CONFLICT (participant_id, slot_id) DO UPDATE SET answer = EXCLUDED.answer`
+
+	for slotID, answer := range answers {
+		_, err = tx.ExecContext(ctx, stmt, id, slotID, string(answer))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ForEvent returns the participants of an event with their answers,
+// in the order they first voted.
+func (m *VoteStore) ForEvent(ctx context.Context, eventID int) (_ []*models.Participant, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `SELECT p.id, p.event_id, COALESCE(p.user_id, 0), p.name, p.updated, v.slot_id, v.answer
+	FROM participants p LEFT JOIN votes v ON v.participant_id = p.id
+	WHERE p.event_id = $1 ORDER BY p.id`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	participants := []*models.Participant{}
+
+	var p *models.Participant
+	for rows.Next() {
+		cur := &models.Participant{Answers: map[int]models.Answer{}}
+
+		var slotID sql.NullInt64
+		var answer sql.NullString
+
+		err = rows.Scan(&cur.ID, &cur.EventID, &cur.UserID, &cur.Name, &cur.Updated, &slotID, &answer)
+		if err != nil {
+			return nil, err
+		}
+
+		// rows are grouped by participant, so only start
+		// a new one when the ID changes
+		if p == nil || p.ID != cur.ID {
+			p = cur
+			participants = append(participants, p)
+		}
+
+		if slotID.Valid && answer.Valid {
+			p.Answers[int(slotID.Int64)] = models.Answer(answer.String)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return participants, nil
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// MaxSearchTerms is the number of terms of a search query that are kept.
+const MaxSearchTerms = 8
+
+// SearchResults is a page of events matching a search, the most relevant
+// first. More is true when other results follow this page.
+type SearchResults struct {
+	Events []*Event
+	More   bool
+}
+
+// SearchTerms splits a search query into lowercase words made of letters
+// and digits, without duplicates. Events match a query when they contain
+// all of its terms, in their title or their description.
+func SearchTerms(query string) []string {
+	terms := []string{}
+	seen := map[string]bool{}
+
+	for _, word := range words(query) {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+
+		terms = append(terms, word)
+		if len(terms) == MaxSearchTerms {
+			break
+		}
+	}
+
+	return terms
+}
+
+// SearchScore ranks an event for the given terms, for the stores that cannot
+// rank results themselves. Occurrences in the title weigh more than those in
+// the description. The score is 0 if any of the terms is missing.
+func SearchScore(evt *Event, terms []string) int {
+	counts := map[string]int{}
+	for _, word := range words(evt.Title) {
+		counts[word] += 3
+	}
+	for _, word := range words(evt.Desc) {
+		counts[word]++
+	}
+
+	score := 0
+	for _, term := range terms {
+		if counts[term] == 0 {
+			return 0
+		}
+		score += counts[term]
+	}
+
+	return score
+}
+
+// RankSearch keeps the events matching all the terms, sorts them by
+// SearchScore, then by time, and returns the requested page. It is meant
+// for the stores that cannot rank results themselves.
+func RankSearch(events []*Event, terms []string, offset, limit int) *SearchResults {
+	scores := map[int]int{}
+	matches := []*Event{}
+
+	for _, evt := range events {
+		if score := SearchScore(evt, terms); score > 0 {
+			scores[evt.ID] = score
+			matches = append(matches, evt)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		switch {
+		case scores[a.ID] != scores[b.ID]:
+			return scores[a.ID] > scores[b.ID]
+		case !a.Time.Equal(b.Time):
+			return a.Time.Before(b.Time)
+		default:
+			return a.ID < b.ID
+		}
+	})
+
+	results := &SearchResults{Events: []*Event{}}
+
+	if offset < len(matches) {
+		matches = matches[offset:]
+		if len(matches) > limit {
+			matches, results.More = matches[:limit], true
+		}
+		results.Events = matches
+	}
+
+	return results
+}
+
+// words returns the lowercase words of s, in order.
+func words(s string) []string {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for i, f := range fields {
+		fields[i] = strings.ToLower(f)
+	}
+
+	return fields
+}
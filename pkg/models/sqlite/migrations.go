@@ -0,0 +1,353 @@
+package sqlite
+
+import "github.com/lobre/doodle/pkg/migrate"
+
+// Migrations holds the changes of the SQLite schema. Once released, a
+// migration must never be edited: add a new one instead. Dates are stored
+// as text in UTC, so that they can be compared as strings.
+var Migrations = []migrate.Migration{
+	{
+		Version: 1,
+		Name:    "create_tables",
+		// The tables of the first release, which may already exist. Later
+		// migrations adding columns to them revert by rebuilding the table,
+		// as SQLite cannot drop a column. They come before the tables
+		// referencing users and events, so that nothing references the
+		// rebuilt table by then.
+		Up: `
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    hashed_password CHAR(60) NOT NULL,
+    created DATETIME NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    CONSTRAINT users_uc_email UNIQUE (email)
+);
+
+CREATE TABLE IF NOT EXISTS events (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    title VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL,
+    time DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_events_time ON events(time);
+`,
+		Down: `
+DROP TABLE events;
+DROP TABLE users;
+`,
+	},
+	{
+		Version: 2,
+		Name:    "add_event_status",
+		Up: `
+ALTER TABLE events ADD COLUMN status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'closed', 'cancelled'));
+`,
+		Down: `
+CREATE TABLE events_new (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    title VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL,
+    time DATETIME NOT NULL
+);
+
+INSERT INTO events_new (id, title, description, time)
+SELECT id, title, description, time FROM events;
+
+DROP TABLE events;
+ALTER TABLE events_new RENAME TO events;
+CREATE INDEX idx_events_time ON events(time);
+`,
+	},
+	{
+		Version: 3,
+		Name:    "add_time_zones",
+		Up: `
+ALTER TABLE users ADD COLUMN time_zone VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN time_zone VARCHAR(64) NOT NULL DEFAULT '';
+`,
+		Down: `
+CREATE TABLE users_new (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    hashed_password CHAR(60) NOT NULL,
+    created DATETIME NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    CONSTRAINT users_uc_email UNIQUE (email)
+);
+
+INSERT INTO users_new (id, name, email, hashed_password, created, active)
+SELECT id, name, email, hashed_password, created, active FROM users;
+
+DROP TABLE users;
+ALTER TABLE users_new RENAME TO users;
+
+CREATE TABLE events_new (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    title VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL,
+    time DATETIME NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'closed', 'cancelled'))
+);
+
+INSERT INTO events_new (id, title, description, time, status)
+SELECT id, title, description, time, status FROM events;
+
+DROP TABLE events;
+ALTER TABLE events_new RENAME TO events;
+CREATE INDEX idx_events_time ON events(time);
+`,
+	},
+	{
+		Version: 4,
+		Name:    "add_feed_token",
+		// SQLite cannot add a column with a unique constraint, hence the
+		// unique index.
+		Up: `
+ALTER TABLE users ADD COLUMN feed_token CHAR(43) NULL;
+CREATE UNIQUE INDEX users_uc_feed_token ON users(feed_token);
+`,
+		Down: `
+DROP INDEX users_uc_feed_token;
+
+CREATE TABLE users_new (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    hashed_password CHAR(60) NOT NULL,
+    created DATETIME NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    time_zone VARCHAR(64) NOT NULL DEFAULT '',
+    CONSTRAINT users_uc_email UNIQUE (email)
+);
+
+INSERT INTO users_new (id, name, email, hashed_password, created, active, time_zone)
+SELECT id, name, email, hashed_password, created, active, time_zone FROM users;
+
+DROP TABLE users;
+ALTER TABLE users_new RENAME TO users;
+`,
+	},
+	{
+		Version: 5,
+		Name:    "add_event_owner",
+		// Events of the first release have no owner.
+		Up: `
+ALTER TABLE events ADD COLUMN user_id INTEGER NULL REFERENCES users(id);
+`,
+		Down: `
+CREATE TABLE events_new (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    title VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL,
+    time DATETIME NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'closed', 'cancelled')),
+    time_zone VARCHAR(64) NOT NULL DEFAULT ''
+);
+
+INSERT INTO events_new (id, title, description, time, status, time_zone)
+SELECT id, title, description, time, status, time_zone FROM events;
+
+DROP TABLE events;
+ALTER TABLE events_new RENAME TO events;
+CREATE INDEX idx_events_time ON events(time);
+`,
+	},
+	{
+		Version: 6,
+		Name:    "create_slots",
+		// Events reference their final slot: to revert, they are rebuilt
+		// without it before the slots are dropped.
+		Up: `
+CREATE TABLE slots (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    start_time DATETIME NOT NULL,
+    end_time DATETIME NOT NULL
+);
+
+CREATE INDEX idx_slots_event_id ON slots(event_id, start_time);
+
+ALTER TABLE events ADD COLUMN final_slot_id INTEGER NULL REFERENCES slots(id) ON DELETE SET NULL;
+`,
+		Down: `
+CREATE TABLE events_new (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    title VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL,
+    time DATETIME NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'closed', 'cancelled')),
+    time_zone VARCHAR(64) NOT NULL DEFAULT '',
+    user_id INTEGER NULL REFERENCES users(id)
+);
+
+INSERT INTO events_new (id, title, description, time, status, time_zone, user_id)
+SELECT id, title, description, time, status, time_zone, user_id FROM events;
+
+DROP TABLE events;
+ALTER TABLE events_new RENAME TO events;
+CREATE INDEX idx_events_time ON events(time);
+
+DROP TABLE slots;
+`,
+	},
+	{
+		Version: 7,
+		Name:    "create_votes",
+		Up: `
+CREATE TABLE participants (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    user_id INTEGER NULL REFERENCES users(id) ON DELETE SET NULL,
+    name VARCHAR(255) NOT NULL,
+    updated DATETIME NOT NULL,
+    CONSTRAINT participants_uc_event_name UNIQUE (event_id, name)
+);
+
+CREATE TABLE votes (
+    participant_id INTEGER NOT NULL REFERENCES participants(id) ON DELETE CASCADE,
+    slot_id INTEGER NOT NULL REFERENCES slots(id) ON DELETE CASCADE,
+    answer TEXT NOT NULL CHECK (answer IN ('yes', 'ifneedbe', 'no')),
+    PRIMARY KEY (participant_id, slot_id)
+);
+`,
+		Down: `
+DROP TABLE votes;
+DROP TABLE participants;
+`,
+	},
+	{
+		Version: 8,
+		Name:    "create_tokens",
+		Up: `
+CREATE TABLE tokens (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    hashed_token CHAR(64) NOT NULL,
+    created DATETIME NOT NULL,
+    last_used DATETIME NULL,
+    CONSTRAINT tokens_uc_hashed_token UNIQUE (hashed_token)
+);
+`,
+		Down: `
+DROP TABLE tokens;
+`,
+	},
+	{
+		Version: 9,
+		Name:    "search_events",
+		// The full-text table only indexes the events, which stay the
+		// source of the content. Triggers keep the index in sync.
+		Up: `
+CREATE VIRTUAL TABLE events_search USING fts4(content="events", title, description, tokenize=unicode61 "remove_diacritics=0");
+
+INSERT INTO events_search (docid, title, description) SELECT id, title, description FROM events;
+
+CREATE TRIGGER events_search_ai AFTER INSERT ON events BEGIN INSERT INTO events_search (docid, title, description) VALUES (new.id, new.title, new.description); END;
+
+CREATE TRIGGER events_search_bu BEFORE UPDATE OF title, description ON events BEGIN DELETE FROM events_search WHERE docid = old.id; END;
+
+CREATE TRIGGER events_search_au AFTER UPDATE OF title, description ON events BEGIN INSERT INTO events_search (docid, title, description) VALUES (new.id, new.title, new.description); END;
+
+CREATE TRIGGER events_search_bd BEFORE DELETE ON events BEGIN DELETE FROM events_search WHERE docid = old.id; END;
+`,
+		Down: `
+DROP TRIGGER events_search_bd;
+DROP TRIGGER events_search_au;
+DROP TRIGGER events_search_bu;
+DROP TRIGGER events_search_ai;
+DROP TABLE events_search;
+`,
+	},
+	{
+		Version: 10,
+		Name:    "create_password_resets",
+		Up: `
+CREATE TABLE password_resets (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    hashed_token CHAR(64) NOT NULL,
+    expires DATETIME NOT NULL,
+    CONSTRAINT password_resets_uc_hashed_token UNIQUE (hashed_token)
+);
+`,
+		Down: `
+DROP TABLE password_resets;
+`,
+	},
+	{
+		Version: 11,
+		Name:    "create_email_verifications",
+		// The state is kept apart from the users table, which could not be
+		// rebuilt to revert a column now that other tables reference it.
+		// Users already registered are considered verified.
+		Up: `
+CREATE TABLE email_verifications (
+    user_id INTEGER NOT NULL PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    hashed_token CHAR(64) NULL,
+    sent DATETIME NOT NULL,
+    expires DATETIME NULL,
+    verified DATETIME NULL,
+    CONSTRAINT email_verifications_uc_hashed_token UNIQUE (hashed_token)
+);
+
+INSERT INTO email_verifications (user_id, email, sent, verified)
+SELECT id, email, created, created FROM users;
+`,
+		Down: `
+DROP TABLE email_verifications;
+`,
+	},
+	{
+		Version: 12,
+		Name:    "create_admin",
+		// Roles and hidden events are kept apart from their tables, for
+		// the same reason as email verifications. Users without a role are
+		// regular ones.
+		Up: `
+CREATE TABLE user_roles (
+    user_id INTEGER NOT NULL PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('admin'))
+);
+
+CREATE TABLE hidden_events (
+    event_id INTEGER NOT NULL PRIMARY KEY REFERENCES events(id) ON DELETE CASCADE,
+    hidden DATETIME NOT NULL
+);
+
+CREATE TABLE audit_log (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    admin_id INTEGER NULL REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(32) NOT NULL,
+    target_id INTEGER NOT NULL,
+    created DATETIME NOT NULL
+);
+`,
+		Down: `
+DROP TABLE audit_log;
+DROP TABLE hidden_events;
+DROP TABLE user_roles;
+`,
+	},
+	{
+		Version: 13,
+		Name:    "create_user_sessions",
+		// The version is raised when the password changes, which ends the
+		// sessions started before. It is kept apart for the same reason as
+		// roles. Users without one are at version 0.
+		Up: `
+CREATE TABLE user_sessions (
+    user_id INTEGER NOT NULL PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL
+);
+`,
+		Down: `
+DROP TABLE user_sessions;
+`,
+	},
+}
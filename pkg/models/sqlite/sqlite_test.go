@@ -0,0 +1,94 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lobre/doodle/pkg/models"
+	"github.com/lobre/doodle/pkg/models/storetest"
+)
+
+func TestStores(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) *storetest.Stores {
+		db, err := sql.Open("sqlite3", "file::memory:?_foreign_keys=on")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// every connection opens a distinct in-memory database
+		db.SetMaxOpenConns(1)
+		t.Cleanup(func() { db.Close() })
+
+		storetest.Migrate(t, db, Migrations)
+
+		return stores(db)
+	})
+}
+
+func TestUpgrade(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file::memory:?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	storetest.Upgrade(t, db, baseline, Migrations, stores(db))
+}
+
+func TestTimeout(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	storetest.Migrate(t, db, Migrations)
+
+	m := &EventStore{DB: db, Timeout: time.Nanosecond}
+
+	_, err = m.Upcoming(context.Background())
+	if !errors.Is(err, models.ErrTimeout) {
+		t.Errorf("want %v; got %v", models.ErrTimeout, err)
+	}
+}
+
+func stores(db *sql.DB) *storetest.Stores {
+	return &storetest.Stores{
+		Events: &EventStore{DB: db},
+		Votes:  &VoteStore{DB: db},
+		Users:  &UserStore{DB: db},
+		Tokens: &TokenStore{DB: db},
+		Resets: &ResetStore{DB: db},
+		Verifs: &VerificationStore{DB: db},
+		Audit:  &AuditStore{DB: db},
+	}
+}
+
+// baseline is the schema of the first release, before migrations existed.
+const baseline = `
+CREATE TABLE events (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    title VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL,
+    time DATETIME NOT NULL
+);
+
+CREATE INDEX idx_events_time ON events(time);
+
+CREATE TABLE users (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    hashed_password CHAR(60) NOT NULL,
+    created DATETIME NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    CONSTRAINT users_uc_email UNIQUE (email)
+);
+`
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// The interfaces below are the contracts between the application and
+// its storage backends. Implementations return ErrNoRecord when a record
+// does not exist, and ErrTimeout when a query exceeds its deadline.
+
+// EventStore holds the events along with their candidate slots. Events
+// hidden by administrators are left out of Upcoming, Search and ForUser,
+// and out of List unless the filter asks for them.
+type EventStore interface {
+	Insert(ctx context.Context, userID int, title, desc string, t time.Time, tz string, slots []*Slot) (int, error)
+	Get(ctx context.Context, id int) (*Event, error)
+	Upcoming(ctx context.Context) ([]*Event, error)
+	List(ctx context.Context, f EventFilter) (*EventPage, error)
+	Search(ctx context.Context, query string, offset, limit int) (*SearchResults, error)
+	ForUser(ctx context.Context, userID int) ([]*Event, error)
+	Update(ctx context.Context, id int, title, desc string) error
+	Delete(ctx context.Context, id int) error
+	Purge(ctx context.Context, before time.Time) (int, error)
+	Close(ctx context.Context, id, slotID int) error
+	Cancel(ctx context.Context, id int) error
+	Reopen(ctx context.Context, id int) error
+	SetHidden(ctx context.Context, id int, hidden bool) error
+}
+
+// VoteStore holds the answers given by participants to the polls. Upsert
+// returns ErrNameTaken when the name has been used by another user.
+type VoteStore interface {
+	Upsert(ctx context.Context, eventID, userID int, name string, answers map[int]Answer) error
+	ForEvent(ctx context.Context, eventID int) ([]*Participant, error)
+}
+
+// UserStore holds the user accounts. Insert and SetEmail return
+// ErrDuplicateEmail when the email is taken, while Authenticate and
+// ChangePassword return ErrInvalidCredentials when the password is wrong
+// or the user inactive. Users are regular ones until given another role.
+// Deactivate, SetRole and Erase return ErrLastAdmin rather than leave no
+// active administrator. ChangePassword, like ResetStore.Reset, raises the
+// session version of the user and deletes their API tokens.
+type UserStore interface {
+	Insert(ctx context.Context, name, email, password string) error
+	Authenticate(ctx context.Context, email, password string) (int, error)
+	Get(ctx context.Context, id int) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	List(ctx context.Context, f UserFilter) (*UserPage, error)
+	SetTimeZone(ctx context.Context, id int, tz string) error
+	SetFeedToken(ctx context.Context, id int, token string) error
+	GetByFeedToken(ctx context.Context, token string) (*User, error)
+	SetName(ctx context.Context, id int, name string) error
+	SetEmail(ctx context.Context, id int, email string) error
+	ChangePassword(ctx context.Context, id int, current, password string) error
+	Activate(ctx context.Context, id int) error
+	Deactivate(ctx context.Context, id int) error
+	SetRole(ctx context.Context, id int, role Role) error
+	Erase(ctx context.Context, id int) error
+}
+
+// TokenStore holds the personal API tokens of the users. Authenticate
+// returns ErrInvalidCredentials when the token is unknown.
+type TokenStore interface {
+	Insert(ctx context.Context, userID int, name, token string) error
+	ForUser(ctx context.Context, userID int) ([]*Token, error)
+	Delete(ctx context.Context, id, userID int) error
+	Authenticate(ctx context.Context, token string) (int, error)
+}
+
+// ResetStore holds the single-use tokens sent by email to let users reset
+// their password. Insert returns ErrNoRecord when no active user has the
+// email, while Check and Reset return ErrInvalidCredentials when the token
+// is unknown, used or expired.
+type ResetStore interface {
+	Insert(ctx context.Context, email, token string, expires time.Time) error
+	Check(ctx context.Context, token string) error
+	Reset(ctx context.Context, token, password string) error
+}
+
+// VerificationStore confirms that users own their email address. A token
+// is sent to the current address of a user, which is verified once the
+// token comes back, until the address changes. Insert replaces the pending
+// token of the user, who is unverified until it comes back, and returns
+// ErrNoRecord when no active user has the email. Sent returns the zero time
+// when no token has ever been sent. Verify returns ErrInvalidCredentials
+// when the token is unknown, expired, or no longer matches the address.
+type VerificationStore interface {
+	Insert(ctx context.Context, email, token string, expires time.Time) error
+	Sent(ctx context.Context, userID int) (time.Time, error)
+	Verify(ctx context.Context, token string) (int, error)
+}
+
+// AuditStore holds the trail of the actions taken by administrators.
+type AuditStore interface {
+	Insert(ctx context.Context, adminID int, action Action, targetID int) error
+	List(ctx context.Context, before, limit int) (*AuditPage, error)
+}
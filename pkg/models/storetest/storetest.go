@@ -0,0 +1,1489 @@
+// Package storetest provides a conformance suite checking that an
+// implementation of the stores behaves as the application expects.
+// Each backend runs it from its own tests.
+package storetest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lobre/doodle/pkg/migrate"
+	"github.com/lobre/doodle/pkg/models"
+)
+
+// Stores groups the implementations to check, sharing the same data.
+type Stores struct {
+	Events models.EventStore
+	Votes  models.VoteStore
+	Users  models.UserStore
+	Tokens models.TokenStore
+	Resets models.ResetStore
+	Verifs models.VerificationStore
+	Audit  models.AuditStore
+}
+
+// Run runs the whole suite. newStores is called for every test
+// and must return stores holding no data.
+func Run(t *testing.T, newStores func(t *testing.T) *Stores) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s *Stores)
+	}{
+		{"Users", testUsers},
+		{"InactiveUsers", testInactiveUsers},
+		{"Account", testAccount},
+		{"Sessions", testSessions},
+		{"Erase", testErase},
+		{"Roles", testRoles},
+		{"LastAdmin", testLastAdmin},
+		{"ListUsers", testListUsers},
+		{"Events", testEvents},
+		{"UpcomingEvents", testUpcomingEvents},
+		{"ListEvents", testListEvents},
+		{"PastEvents", testPastEvents},
+		{"SearchEvents", testSearchEvents},
+		{"HiddenEvents", testHiddenEvents},
+		{"EventLifecycle", testEventLifecycle},
+		{"Votes", testVotes},
+		{"Tokens", testTokens},
+		{"Resets", testResets},
+		{"Verifications", testVerifications},
+		{"Audit", testAudit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStores(t))
+		})
+	}
+}
+
+// Migrate brings the schema of db up to date from scratch, and drops it
+// at the end of the test. Any data already in db is lost.
+func Migrate(t *testing.T, db *sql.DB, migrations []migrate.Migration) {
+	t.Helper()
+
+	m := &migrate.Migrator{DB: db, Migrations: migrations}
+
+	if err := reset(m); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err := reset(m); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+// Upgrade checks that the migrations bring a database created with the
+// schema of the first release, given as baseline, up to date while keeping
+// its data, and that they revert back to it. s must use db. Any data
+// already in db is lost.
+func Upgrade(t *testing.T, db *sql.DB, baseline string, migrations []migrate.Migration, s *Stores) {
+	m := &migrate.Migrator{DB: db, Migrations: migrations}
+
+	if err := reset(m); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err := reset(m); err != nil {
+			t.Error(err)
+		}
+	})
+
+	// the literals are understood by all the databases
+	stmts := append(strings.Split(baseline, ";\n"),
+		`INSERT INTO users (name, email, hashed_password, created, active)
+		VALUES ('Alice', 'alice@example.com', '$2a$12$AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA', '2020-01-02 03:04:05', TRUE)`,
+		`INSERT INTO events (title, description, time)
+		VALUES ('Jam session', 'Will be so cool', '2040-06-21 18:00:00')`,
+	)
+
+	for _, stmt := range stmts {
+		if stmt = strings.TrimSpace(stmt); stmt == "" {
+			continue
+		}
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := s.Users.Get(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "alice@example.com" || !user.Active || !user.Verified || user.Role != models.RoleUser {
+		t.Errorf("want existing user to be an active and verified regular user; got %+v", user)
+	}
+
+	evt, err := s.Events.Get(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evt.Title != "Jam session" || evt.UserID != 0 || evt.Status != models.StatusOpen || len(evt.Slots) != 0 {
+		t.Errorf("want existing event to be open without owner nor slots; got %+v", evt)
+	}
+
+	results, err := s.Events.Search(ctx, "jam", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameIDs(results.Events, []int{1}) {
+		t.Errorf("want existing event to be searchable; got %v", ids(results.Events))
+	}
+
+	// the new tables and columns work along the existing data
+	insertEvent(t, s, user.ID, now().Add(24*time.Hour))
+
+	for range migrations[1:] {
+		if _, err := m.Down(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var title string
+	err = db.QueryRow(`SELECT title FROM events WHERE id = 1`).Scan(&title)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title != "Jam session" {
+		t.Errorf("want existing event to be kept when reverting; got %q", title)
+	}
+}
+
+// reset reverts all the applied migrations.
+func reset(m *migrate.Migrator) error {
+	for {
+		_, err := m.Down()
+		if errors.Is(err, migrate.ErrNoChange) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// ctx is given to all the calls to the stores.
+var ctx = context.Background()
+
+// now is truncated as not all databases keep fractions of seconds.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// insertUser inserts a user and returns its ID.
+func insertUser(t *testing.T, s *Stores, name, email string) int {
+	t.Helper()
+
+	if err := s.Users.Insert(ctx, name, email, "pa$$word123"); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := s.Users.Authenticate(ctx, email, "pa$$word123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id
+}
+
+// insertEvent inserts an event with two slots, starting in a day.
+func insertEvent(t *testing.T, s *Stores, userID int, when time.Time) *models.Event {
+	t.Helper()
+
+	slots := []*models.Slot{
+		{Start: when.Add(2 * time.Hour), End: when.Add(3 * time.Hour)},
+		{Start: when, End: when.Add(time.Hour)},
+	}
+
+	id, err := s.Events.Insert(ctx, userID, "Rehearsal", "Bring your instrument", when, "Europe/Paris", slots)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &models.Event{ID: id, UserID: userID, Time: when, Slots: slots}
+}
+
+func testUsers(t *testing.T, s *Stores) {
+	id := insertUser(t, s, "Alice", "alice@example.com")
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantID   int
+		wantErr  error
+	}{
+		{"Valid credentials", "alice@example.com", "pa$$word123", id, nil},
+		{"Wrong password", "alice@example.com", "wrong", 0, models.ErrInvalidCredentials},
+		{"Unknown email", "bob@example.com", "pa$$word123", 0, models.ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Users.Authenticate(ctx, tt.email, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want error %v; got %v", tt.wantErr, err)
+			}
+			if got != tt.wantID {
+				t.Errorf("want ID %d; got %d", tt.wantID, got)
+			}
+		})
+	}
+
+	err := s.Users.Insert(ctx, "Other Alice", "alice@example.com", "pa$$word123")
+	if !errors.Is(err, models.ErrDuplicateEmail) {
+		t.Errorf("want %v; got %v", models.ErrDuplicateEmail, err)
+	}
+
+	user, err := s.Users.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "Alice" || user.Email != "alice@example.com" || !user.Active {
+		t.Errorf("unexpected user %+v", user)
+	}
+	if user.Created.IsZero() {
+		t.Errorf("want creation time to be set")
+	}
+
+	if _, err = s.Users.Get(ctx, id+1000); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+	}
+
+	if err = s.Users.SetTimeZone(ctx, id, "Europe/Paris"); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Users.SetFeedToken(ctx, id, "feed-token"); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err = s.Users.GetByFeedToken(ctx, "feed-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != id || user.TimeZone != "Europe/Paris" || user.FeedToken != "feed-token" {
+		t.Errorf("unexpected user %+v", user)
+	}
+
+	if _, err = s.Users.GetByFeedToken(ctx, "unknown"); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+	}
+}
+
+func testAccount(t *testing.T, s *Stores) {
+	alice := insertUser(t, s, "Alice", "alice@example.com")
+	insertUser(t, s, "Bob", "bob@example.com")
+
+	if err := s.Users.SetName(ctx, alice, "Alice Liddell"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Verifs.Insert(ctx, "alice@example.com", "verify", now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Verifs.Verify(ctx, "verify"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Users.SetEmail(ctx, alice, "bob@example.com"); !errors.Is(err, models.ErrDuplicateEmail) {
+		t.Errorf("want %v; got %v", models.ErrDuplicateEmail, err)
+	}
+	if err := s.Users.SetEmail(ctx, alice, "liddell@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := s.Users.Get(ctx, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "Alice Liddell" || user.Email != "liddell@example.com" {
+		t.Errorf("unexpected user %+v", user)
+	}
+	if user.Verified {
+		t.Errorf("want new address to be unverified")
+	}
+
+	if err = s.Resets.Insert(ctx, "liddell@example.com", "reset", now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Users.ChangePassword(ctx, alice, "wrong", "new-pa$$word")
+	if !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Errorf("want %v for a wrong current password; got %v", models.ErrInvalidCredentials, err)
+	}
+
+	if err = s.Users.ChangePassword(ctx, alice, "pa$$word123", "new-pa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Users.Authenticate(ctx, "liddell@example.com", "new-pa$$word"); err != nil {
+		t.Errorf("want new password to be accepted; got %v", err)
+	}
+	if err = s.Resets.Check(ctx, "reset"); !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Errorf("want pending resets to be dropped; got %v", err)
+	}
+}
+
+func testSessions(t *testing.T, s *Stores) {
+	alice := insertUser(t, s, "Alice", "alice@example.com")
+	bob := insertUser(t, s, "Bob", "bob@example.com")
+
+	if err := s.Tokens.Insert(ctx, alice, "Script", "alice-token"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Tokens.Insert(ctx, bob, "Script", "bob-token"); err != nil {
+		t.Fatal(err)
+	}
+
+	// version checks the session version of the user and that their API
+	// token is revoked
+	version := func(want int) {
+		t.Helper()
+
+		user, err := s.Users.Get(ctx, alice)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.SessionVersion != want {
+			t.Errorf("want session version %d; got %d", want, user.SessionVersion)
+		}
+
+		if _, err = s.Tokens.Authenticate(ctx, "alice-token"); want > 0 && !errors.Is(err, models.ErrInvalidCredentials) {
+			t.Errorf("want API token to be revoked; got %v", err)
+		}
+	}
+
+	version(0)
+
+	if err := s.Users.ChangePassword(ctx, alice, "pa$$word123", "new-pa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	version(1)
+
+	if err := s.Resets.Insert(ctx, "alice@example.com", "reset", now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Resets.Reset(ctx, "reset", "other-pa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	version(2)
+
+	if _, err := s.Tokens.Authenticate(ctx, "bob-token"); err != nil {
+		t.Errorf("want API tokens of others to be kept; got %v", err)
+	}
+
+	users, err := s.Users.List(ctx, models.UserFilter{Query: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users.Users) != 1 || users.Users[0].SessionVersion != 2 {
+		t.Errorf("want listed users to have their session version")
+	}
+}
+
+func testErase(t *testing.T, s *Stores) {
+	alice := insertUser(t, s, "Alice", "alice@example.com")
+	bob := insertUser(t, s, "Bob", "bob@example.com")
+
+	own := insertEvent(t, s, alice, now().Add(24*time.Hour))
+	other := insertEvent(t, s, bob, now().Add(48*time.Hour))
+
+	evt, err := s.Events.Get(ctx, other.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slotID := evt.Slots[0].ID
+
+	if err = s.Votes.Upsert(ctx, other.ID, alice, "Alice", map[int]models.Answer{slotID: models.AnswerYes}); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Votes.Upsert(ctx, other.ID, 0, "Carol", map[int]models.Answer{slotID: models.AnswerNo}); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Tokens.Insert(ctx, alice, "CI", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.Users.Erase(ctx, alice); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = s.Users.Get(ctx, alice); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want user to be deleted; got %v", err)
+	}
+	if _, err = s.Events.Get(ctx, own.ID); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want events of the user to be deleted; got %v", err)
+	}
+	if _, err = s.Tokens.Authenticate(ctx, "secret"); !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Errorf("want tokens of the user to be deleted; got %v", err)
+	}
+
+	// the email can be used again
+	insertUser(t, s, "Alice", "alice@example.com")
+
+	participants, err := s.Votes.ForEvent(ctx, other.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(participants) != 2 {
+		t.Fatalf("want votes on the events of others to be kept; got %d participants", len(participants))
+	}
+
+	erased, carol := participants[0], participants[1]
+	if erased.UserID != 0 || erased.Name != fmt.Sprintf("%s #%d", models.ErasedName, erased.ID) {
+		t.Errorf("want participant to be anonymised; got %+v", erased)
+	}
+	if erased.Answers[slotID] != models.AnswerYes {
+		t.Errorf("want answers to be kept; got %v", erased.Answers)
+	}
+	if carol.Name != "Carol" {
+		t.Errorf("want other participants to be kept; got %+v", carol)
+	}
+
+	if _, err = s.Users.Get(ctx, bob); err != nil {
+		t.Errorf("want other users to be kept; got %v", err)
+	}
+}
+
+func testInactiveUsers(t *testing.T, s *Stores) {
+	id := insertUser(t, s, "Alice", "alice@example.com")
+
+	if err := s.Users.SetFeedToken(ctx, id, "feed-token"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Users.Deactivate(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Users.Authenticate(ctx, "alice@example.com", "pa$$word123"); !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Errorf("want %v; got %v", models.ErrInvalidCredentials, err)
+	}
+
+	if _, err := s.Users.GetByFeedToken(ctx, "feed-token"); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+	}
+
+	// the user is still there, so that the application can tell it is disabled
+	user, err := s.Users.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Active {
+		t.Errorf("want user to be inactive")
+	}
+}
+
+func testRoles(t *testing.T, s *Stores) {
+	id := insertUser(t, s, "Alice", "alice@example.com")
+
+	user, err := s.Users.GetByEmail(ctx, "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != id || user.Role != models.RoleUser || user.IsAdmin() {
+		t.Errorf("want regular user %d; got %+v", id, user)
+	}
+
+	if _, err = s.Users.GetByEmail(ctx, "bob@example.com"); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+	}
+
+	// promoting twice is harmless
+	for i := 0; i < 2; i++ {
+		if err = s.Users.SetRole(ctx, id, models.RoleAdmin); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	user, err = s.Users.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.IsAdmin() {
+		t.Errorf("want user to be an administrator; got %q", user.Role)
+	}
+
+	// another administrator remains
+	bob := insertUser(t, s, "Bob", "bob@example.com")
+	if err = s.Users.SetRole(ctx, bob, models.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.Users.SetRole(ctx, id, models.RoleUser); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err = s.Users.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != models.RoleUser {
+		t.Errorf("want role %q; got %q", models.RoleUser, user.Role)
+	}
+
+	if err = s.Users.Deactivate(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Users.Activate(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = s.Users.Authenticate(ctx, "alice@example.com", "pa$$word123"); err != nil {
+		t.Errorf("want reactivated user to log in; got %v", err)
+	}
+}
+
+func testLastAdmin(t *testing.T, s *Stores) {
+	alice := insertUser(t, s, "Alice", "alice@example.com")
+	bob := insertUser(t, s, "Bob", "bob@example.com")
+	carol := insertUser(t, s, "Carol", "carol@example.com")
+
+	for _, id := range []int{alice, bob} {
+		if err := s.Users.SetRole(ctx, id, models.RoleAdmin); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// an inactive administrator does not count
+	if err := s.Users.Deactivate(ctx, bob); err != nil {
+		t.Fatal(err)
+	}
+
+	actions := []struct {
+		name string
+		fn   func() error
+	}{
+		{"Deactivate", func() error { return s.Users.Deactivate(ctx, alice) }},
+		{"Demote", func() error { return s.Users.SetRole(ctx, alice, models.RoleUser) }},
+		{"Erase", func() error { return s.Users.Erase(ctx, alice) }},
+	}
+
+	for _, a := range actions {
+		if err := a.fn(); !errors.Is(err, models.ErrLastAdmin) {
+			t.Errorf("%s: want %v; got %v", a.name, models.ErrLastAdmin, err)
+		}
+	}
+
+	user, err := s.Users.Get(ctx, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.Active || !user.IsAdmin() {
+		t.Errorf("want user to stay an active administrator; got %+v", user)
+	}
+
+	// promoting the last one again is harmless
+	if err = s.Users.SetRole(ctx, alice, models.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+
+	// regular users are not concerned
+	if err = s.Users.SetRole(ctx, carol, models.RoleUser); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Users.Deactivate(ctx, carol); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.Users.Activate(ctx, bob); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Users.Deactivate(ctx, alice); err != nil {
+		t.Errorf("want administrator to be deactivated once another is active; got %v", err)
+	}
+}
+
+func testListUsers(t *testing.T, s *Stores) {
+	alice := insertUser(t, s, "Alice", "alice@example.com")
+	bob := insertUser(t, s, "Bob", "bob@example.com")
+	carol := insertUser(t, s, "Carol 100%", "carol@example.org")
+
+	if err := s.Users.SetRole(ctx, carol, models.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		filter    models.UserFilter
+		wantIDs   []int
+		wantPages int
+	}{
+		{"All", models.UserFilter{}, []int{alice, bob, carol}, 1},
+		{"Pages", models.UserFilter{Limit: 2}, []int{alice, bob, carol}, 2},
+		{"Name", models.UserFilter{Query: "BOB"}, []int{bob}, 1},
+		{"Email", models.UserFilter{Query: "example.com"}, []int{alice, bob}, 1},
+		{"Wildcard", models.UserFilter{Query: "%"}, []int{carol}, 1},
+		{"None", models.UserFilter{Query: "dave"}, []int{}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []int{}
+			pages := 0
+
+			f := tt.filter
+			for {
+				page, err := s.Users.List(ctx, f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				pages++
+
+				for _, u := range page.Users {
+					got = append(got, u.ID)
+					if u.ID == carol && !u.IsAdmin() {
+						t.Errorf("want user %d to be an administrator", u.ID)
+					}
+				}
+
+				if page.Next == 0 {
+					break
+				}
+				if pages > len(tt.wantIDs) {
+					t.Fatalf("want at most %d pages; got more", len(tt.wantIDs))
+				}
+				f.After = page.Next
+			}
+
+			if !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Errorf("want users %v; got %v", tt.wantIDs, got)
+			}
+			if pages != tt.wantPages {
+				t.Errorf("want %d pages; got %d", tt.wantPages, pages)
+			}
+		})
+	}
+}
+
+func testEvents(t *testing.T, s *Stores) {
+	userID := insertUser(t, s, "Alice", "alice@example.com")
+	when := now().Add(24 * time.Hour)
+	want := insertEvent(t, s, userID, when)
+
+	evt, err := s.Events.Get(ctx, want.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if evt.UserID != userID || evt.Title != "Rehearsal" || evt.Desc != "Bring your instrument" {
+		t.Errorf("unexpected event %+v", evt)
+	}
+	if !evt.Time.Equal(when) {
+		t.Errorf("want time %v; got %v", when, evt.Time)
+	}
+	if evt.TimeZone != "Europe/Paris" || evt.Status != models.StatusOpen || evt.FinalSlotID != 0 {
+		t.Errorf("unexpected event %+v", evt)
+	}
+
+	if len(evt.Slots) != 2 {
+		t.Fatalf("want 2 slots; got %d", len(evt.Slots))
+	}
+	// slots are ordered chronologically, whatever the insertion order
+	if !evt.Slots[0].Start.Equal(when) || !evt.Slots[1].Start.Equal(when.Add(2*time.Hour)) {
+		t.Errorf("want slots ordered by start; got %v and %v", evt.Slots[0].Start, evt.Slots[1].Start)
+	}
+	if !evt.Slots[0].End.Equal(when.Add(time.Hour)) {
+		t.Errorf("want slot end %v; got %v", when.Add(time.Hour), evt.Slots[0].End)
+	}
+
+	if _, err = s.Events.Get(ctx, want.ID+1000); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+	}
+
+	other := insertUser(t, s, "Bob", "bob@example.com")
+	voted := insertEvent(t, s, other, when)
+
+	evt, err = s.Events.Get(ctx, voted.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Votes.Upsert(ctx, voted.ID, userID, "Alice", map[int]models.Answer{evt.Slots[0].ID: models.AnswerYes})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		userID  int
+		wantIDs []int
+	}{
+		{"Created and voted", userID, []int{want.ID, voted.ID}},
+		{"Created only", other, []int{voted.ID}},
+	}
+
+	for _, tt := range tests {
+		t.Run("ForUser/"+tt.name, func(t *testing.T) {
+			events, err := s.Events.ForUser(ctx, tt.userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sameIDs(events, tt.wantIDs) {
+				t.Errorf("want events %v; got %v", tt.wantIDs, ids(events))
+			}
+			for _, evt := range events {
+				if len(evt.Slots) != 2 {
+					t.Errorf("want slots of event %d to be loaded", evt.ID)
+				}
+			}
+		})
+	}
+}
+
+func testUpcomingEvents(t *testing.T, s *Stores) {
+	userID := insertUser(t, s, "Alice", "alice@example.com")
+	past := insertEvent(t, s, userID, now().Add(-time.Hour))
+	future := insertEvent(t, s, userID, now().Add(time.Hour))
+
+	events, err := s.Events.Upcoming(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameIDs(events, []int{future.ID}) {
+		t.Errorf("want events %v; got %v", []int{future.ID}, ids(events))
+	}
+
+	// past events stay reachable, for the record
+	evt, err := s.Events.Get(ctx, past.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !evt.Past() {
+		t.Errorf("want event to be past")
+	}
+}
+
+func testListEvents(t *testing.T, s *Stores) {
+	alice := insertUser(t, s, "Alice", "alice@example.com")
+	bob := insertUser(t, s, "Bob", "bob@example.com")
+
+	insertEvent(t, s, alice, now().Add(-time.Hour))
+	first := insertEvent(t, s, alice, now().Add(time.Hour))
+	second := insertEvent(t, s, bob, now().Add(2*time.Hour))
+	third := insertEvent(t, s, alice, now().Add(2*time.Hour))
+	last := insertEvent(t, s, alice, now().Add(48*time.Hour))
+
+	tests := []struct {
+		name      string
+		filter    models.EventFilter
+		wantIDs   []int
+		wantPages int
+	}{
+		{"Ascending", models.EventFilter{Limit: 2}, []int{first.ID, second.ID, third.ID, last.ID}, 2},
+		{"Descending", models.EventFilter{Desc: true, Limit: 3}, []int{last.ID, third.ID, second.ID, first.ID}, 2},
+		{"Single page", models.EventFilter{Limit: 10}, []int{first.ID, second.ID, third.ID, last.ID}, 1},
+		{"Owner", models.EventFilter{UserID: alice, Limit: 1}, []int{first.ID, third.ID, last.ID}, 3},
+		{"Date range", models.EventFilter{From: now().Add(90 * time.Minute), To: now().Add(24 * time.Hour)}, []int{second.ID, third.ID}, 1},
+		{"Empty range", models.EventFilter{From: now().Add(72 * time.Hour)}, []int{}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []int{}
+			pages := 0
+
+			f := tt.filter
+			for {
+				page, err := s.Events.List(ctx, f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				pages++
+				got = append(got, ids(page.Events)...)
+
+				if page.Next == nil {
+					break
+				}
+				if pages > len(tt.wantIDs) {
+					t.Fatalf("want at most %d pages; got more", len(tt.wantIDs))
+				}
+
+				// cursors go through URLs, so decode them as handlers do
+				f.After, err = models.ParseCursor(page.Next.String())
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Errorf("want events %v; got %v", tt.wantIDs, got)
+			}
+			if pages != tt.wantPages {
+				t.Errorf("want %d pages; got %d", tt.wantPages, pages)
+			}
+		})
+	}
+}
+
+func testPastEvents(t *testing.T, s *Stores) {
+	userID := insertUser(t, s, "Alice", "alice@example.com")
+	old := insertEvent(t, s, userID, now().Add(-48*time.Hour))
+	recent := insertEvent(t, s, userID, now().Add(-time.Hour))
+	insertEvent(t, s, userID, now().Add(time.Hour))
+
+	err := s.Votes.Upsert(ctx, old.ID, 0, "Bob", map[int]models.Answer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive := func() []int {
+		page, err := s.Events.List(ctx, models.EventFilter{Past: true, Desc: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ids(page.Events)
+	}
+
+	if got, want := archive(), []int{recent.ID, old.ID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want past events %v; got %v", want, got)
+	}
+
+	n, err := s.Events.Purge(ctx, now().Add(-24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("want 1 event purged; got %d", n)
+	}
+
+	if got, want := archive(), []int{recent.ID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want past events %v after purge; got %v", want, got)
+	}
+
+	if _, err = s.Events.Get(ctx, old.ID); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want purged event to be gone; got %v", err)
+	}
+
+	participants, err := s.Votes.ForEvent(ctx, old.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(participants) != 0 {
+		t.Errorf("want votes of purged event to be gone; got %d", len(participants))
+	}
+}
+
+func testSearchEvents(t *testing.T, s *Stores) {
+	userID := insertUser(t, s, "Alice", "alice@example.com")
+
+	insert := func(title, desc string, when time.Time) int {
+		slots := []*models.Slot{{Start: when, End: when.Add(time.Hour)}}
+		id, err := s.Events.Insert(ctx, userID, title, desc, when, "", slots)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	rehearsal := insert("Band rehearsal", "The whole band plays", now().Add(48*time.Hour))
+	meeting := insert("Board meeting", "Budget of the band", now().Add(24*time.Hour))
+	quiz := insert("Quiz night", "Teams of four", now().Add(24*time.Hour))
+	insert("Band rehearsal", "Already happened", now().Add(-time.Hour))
+
+	tests := []struct {
+		name     string
+		query    string
+		offset   int
+		limit    int
+		wantIDs  []int
+		wantMore bool
+	}{
+		{"Ranked", "band", 0, 10, []int{rehearsal, meeting}, false},
+		{"All terms", "BAND, rehearsal!", 0, 10, []int{rehearsal}, false},
+		{"Description", "teams", 0, 10, []int{quiz}, false},
+		{"No match", "rehearsal quiz", 0, 10, []int{}, false},
+		{"Blank", " ", 0, 10, []int{}, false},
+		{"First page", "band", 0, 1, []int{rehearsal}, true},
+		{"Last page", "band", 1, 1, []int{meeting}, false},
+		{"Beyond last page", "band", 2, 1, []int{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := s.Events.Search(ctx, tt.query, tt.offset, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := ids(results.Events); !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Errorf("want events %v; got %v", tt.wantIDs, got)
+			}
+			if results.More != tt.wantMore {
+				t.Errorf("want more %t; got %t", tt.wantMore, results.More)
+			}
+		})
+	}
+
+	// the index must follow the changes of the events
+	err := s.Events.Update(ctx, quiz, "Pub quiz", "Teams of five")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for query, want := range map[string][]int{"four": {}, "five": {quiz}} {
+		results, err := s.Events.Search(ctx, query, 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(results.Events); !reflect.DeepEqual(got, want) {
+			t.Errorf("want events %v for %q after update; got %v", want, query, got)
+		}
+	}
+}
+
+func testHiddenEvents(t *testing.T, s *Stores) {
+	userID := insertUser(t, s, "Alice", "alice@example.com")
+	shown := insertEvent(t, s, userID, now().Add(time.Hour))
+	hidden := insertEvent(t, s, userID, now().Add(2*time.Hour))
+
+	// hiding twice is harmless
+	for i := 0; i < 2; i++ {
+		if err := s.Events.SetHidden(ctx, hidden.ID, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	evt, err := s.Events.Get(ctx, hidden.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !evt.Hidden {
+		t.Errorf("want event to be hidden")
+	}
+
+	upcoming, err := s.Events.Upcoming(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameIDs(upcoming, []int{shown.ID}) {
+		t.Errorf("want upcoming events %v; got %v", []int{shown.ID}, ids(upcoming))
+	}
+
+	page, err := s.Events.List(ctx, models.EventFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameIDs(page.Events, []int{shown.ID}) {
+		t.Errorf("want listed events %v; got %v", []int{shown.ID}, ids(page.Events))
+	}
+
+	page, err = s.Events.List(ctx, models.EventFilter{Hidden: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameIDs(page.Events, []int{shown.ID, hidden.ID}) {
+		t.Errorf("want listed events %v; got %v", []int{shown.ID, hidden.ID}, ids(page.Events))
+	}
+	if len(page.Events) == 2 && (page.Events[0].Hidden || !page.Events[1].Hidden) {
+		t.Errorf("want only event %d to be flagged as hidden", hidden.ID)
+	}
+
+	results, err := s.Events.Search(ctx, "rehearsal", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameIDs(results.Events, []int{shown.ID}) {
+		t.Errorf("want search results %v; got %v", []int{shown.ID}, ids(results.Events))
+	}
+
+	// not even their owner gets the hidden events in their feed
+	owned, err := s.Events.ForUser(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameIDs(owned, []int{shown.ID}) {
+		t.Errorf("want events of the user %v; got %v", []int{shown.ID}, ids(owned))
+	}
+
+	if err = s.Events.SetHidden(ctx, hidden.ID, false); err != nil {
+		t.Fatal(err)
+	}
+
+	upcoming, err = s.Events.Upcoming(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameIDs(upcoming, []int{shown.ID, hidden.ID}) {
+		t.Errorf("want upcoming events %v; got %v", []int{shown.ID, hidden.ID}, ids(upcoming))
+	}
+
+	// titles are matched ignoring case, and wildcards are taken literally
+	page, err = s.Events.List(ctx, models.EventFilter{Title: "REHEARS"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameIDs(page.Events, []int{shown.ID, hidden.ID}) {
+		t.Errorf("want events %v; got %v", []int{shown.ID, hidden.ID}, ids(page.Events))
+	}
+
+	page, err = s.Events.List(ctx, models.EventFilter{Title: "re_earsal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Events) != 0 {
+		t.Errorf("want no events; got %v", ids(page.Events))
+	}
+}
+
+func testEventLifecycle(t *testing.T, s *Stores) {
+	userID := insertUser(t, s, "Alice", "alice@example.com")
+	created := insertEvent(t, s, userID, now().Add(24*time.Hour))
+
+	evt, err := s.Events.Get(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slotID := evt.Slots[1].ID
+
+	steps := []struct {
+		name       string
+		fn         func() error
+		wantStatus models.Status
+		wantSlotID int
+	}{
+		{"Close", func() error { return s.Events.Close(ctx, evt.ID, slotID) }, models.StatusClosed, slotID},
+		{"Reopen closed", func() error { return s.Events.Reopen(ctx, evt.ID) }, models.StatusOpen, 0},
+		{"Cancel", func() error { return s.Events.Cancel(ctx, evt.ID) }, models.StatusCancelled, 0},
+		{"Reopen cancelled", func() error { return s.Events.Reopen(ctx, evt.ID) }, models.StatusOpen, 0},
+	}
+
+	for _, st := range steps {
+		if err := st.fn(); err != nil {
+			t.Fatalf("%s: %s", st.name, err)
+		}
+
+		got, err := s.Events.Get(ctx, evt.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != st.wantStatus || got.FinalSlotID != st.wantSlotID {
+			t.Errorf("%s: want %s on slot %d; got %s on slot %d", st.name, st.wantStatus, st.wantSlotID, got.Status, got.FinalSlotID)
+		}
+	}
+
+	if err = s.Events.Update(ctx, evt.ID, "New title", "New description"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Events.Get(ctx, evt.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "New title" || got.Desc != "New description" {
+		t.Errorf("want event to be updated; got %q and %q", got.Title, got.Desc)
+	}
+
+	err = s.Votes.Upsert(ctx, evt.ID, 0, "Bob", map[int]models.Answer{slotID: models.AnswerYes})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.Events.Delete(ctx, evt.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Events.Get(ctx, evt.ID); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+	}
+
+	participants, err := s.Votes.ForEvent(ctx, evt.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(participants) != 0 {
+		t.Errorf("want votes to be deleted along the event")
+	}
+}
+
+func testVotes(t *testing.T, s *Stores) {
+	userID := insertUser(t, s, "Alice", "alice@example.com")
+	created := insertEvent(t, s, userID, now().Add(24*time.Hour))
+
+	evt, err := s.Events.Get(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := evt.Slots[0].ID, evt.Slots[1].ID
+
+	votes := []struct {
+		userID  int
+		name    string
+		answers map[int]models.Answer
+	}{
+		{0, "Bob", map[int]models.Answer{first: models.AnswerYes, second: models.AnswerNo}},
+		{userID, "Alice", map[int]models.Answer{first: models.AnswerIfNeedBe}},
+		// voting again under the same name replaces the answers
+		{0, "Bob", map[int]models.Answer{first: models.AnswerNo, second: models.AnswerYes}},
+	}
+
+	for _, v := range votes {
+		if err := s.Votes.Upsert(ctx, evt.ID, v.userID, v.name, v.answers); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the answers of a user cannot be replaced by others
+	otherID := insertUser(t, s, "Mallory", "mallory@example.com")
+	for _, uid := range []int{0, otherID} {
+		err = s.Votes.Upsert(ctx, evt.ID, uid, "Alice", map[int]models.Answer{first: models.AnswerNo, second: models.AnswerNo})
+		if !errors.Is(err, models.ErrNameTaken) {
+			t.Errorf("want %v; got %v", models.ErrNameTaken, err)
+		}
+	}
+
+	// an anonymous participant does not become the user's
+	err = s.Votes.Upsert(ctx, evt.ID, otherID, "Bob", map[int]models.Answer{first: models.AnswerNo})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	participants, err := s.Votes.ForEvent(ctx, evt.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(participants) != 2 {
+		t.Fatalf("want 2 participants; got %d", len(participants))
+	}
+
+	// participants are kept in the order they first voted
+	bob, alice := participants[0], participants[1]
+
+	if bob.Name != "Bob" || bob.UserID != 0 || bob.EventID != evt.ID {
+		t.Errorf("unexpected participant %+v", bob)
+	}
+	if bob.Answers[first] != models.AnswerNo || bob.Answers[second] != models.AnswerYes {
+		t.Errorf("want answers to be replaced; got %v", bob.Answers)
+	}
+	if bob.Updated.IsZero() {
+		t.Errorf("want update time to be set")
+	}
+
+	if alice.Name != "Alice" || alice.UserID != userID || len(alice.Answers) != 1 {
+		t.Errorf("unexpected participant %+v", alice)
+	}
+
+	participants, err = s.Votes.ForEvent(ctx, evt.ID+1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(participants) != 0 {
+		t.Errorf("want no participant; got %d", len(participants))
+	}
+}
+
+func testTokens(t *testing.T, s *Stores) {
+	alice := insertUser(t, s, "Alice", "alice@example.com")
+	bob := insertUser(t, s, "Bob", "bob@example.com")
+
+	if err := s.Tokens.Insert(ctx, alice, "CI", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, err := s.Tokens.ForUser(ctx, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 || tokens[0].Name != "CI" || tokens[0].UserID != alice {
+		t.Fatalf("unexpected tokens %+v", tokens)
+	}
+	if !tokens[0].LastUsed.IsZero() {
+		t.Errorf("want token to be unused")
+	}
+
+	id, err := s.Tokens.Authenticate(ctx, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != alice {
+		t.Errorf("want user %d; got %d", alice, id)
+	}
+
+	tokens, err = s.Tokens.ForUser(ctx, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens[0].LastUsed.IsZero() {
+		t.Errorf("want last use to be recorded")
+	}
+
+	if _, err = s.Tokens.Authenticate(ctx, "wrong"); !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Errorf("want %v; got %v", models.ErrInvalidCredentials, err)
+	}
+
+	if err = s.Tokens.Delete(ctx, tokens[0].ID, bob); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want tokens of others to be kept; got %v", err)
+	}
+
+	if err = s.Tokens.Delete(ctx, tokens[0].ID, alice); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Tokens.Authenticate(ctx, "secret"); !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Errorf("want revoked token to be rejected; got %v", err)
+	}
+}
+
+func testResets(t *testing.T, s *Stores) {
+	alice := insertUser(t, s, "Alice", "alice@example.com")
+	bob := insertUser(t, s, "Bob", "bob@example.com")
+	expires := now().Add(time.Hour)
+
+	if err := s.Resets.Insert(ctx, "nobody@example.com", "unknown", expires); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v for an unknown email; got %v", models.ErrNoRecord, err)
+	}
+
+	for _, token := range []string{"first", "second"} {
+		if err := s.Resets.Insert(ctx, "alice@example.com", token, expires); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Resets.Insert(ctx, "bob@example.com", "expired", now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Resets.Check(ctx, "first"); err != nil {
+		t.Errorf("want token to be valid; got %v", err)
+	}
+
+	for _, token := range []string{"wrong", "expired"} {
+		if err := s.Resets.Check(ctx, token); !errors.Is(err, models.ErrInvalidCredentials) {
+			t.Errorf("want %v for token %q; got %v", models.ErrInvalidCredentials, token, err)
+		}
+		if err := s.Resets.Reset(ctx, token, "new-pa$$word"); !errors.Is(err, models.ErrInvalidCredentials) {
+			t.Errorf("want %v for token %q; got %v", models.ErrInvalidCredentials, token, err)
+		}
+	}
+
+	if err := s.Resets.Reset(ctx, "first", "new-pa$$word"); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := s.Users.Authenticate(ctx, "alice@example.com", "new-pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != alice {
+		t.Errorf("want user %d; got %d", alice, id)
+	}
+	if _, err = s.Users.Authenticate(ctx, "alice@example.com", "pa$$word123"); !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Errorf("want old password to be rejected; got %v", err)
+	}
+	if _, err = s.Users.Authenticate(ctx, "bob@example.com", "pa$$word123"); err != nil {
+		t.Errorf("want password of others to be kept; got %v", err)
+	}
+
+	// all the tokens of the user are used up by a reset
+	for _, token := range []string{"first", "second"} {
+		if err = s.Resets.Check(ctx, token); !errors.Is(err, models.ErrInvalidCredentials) {
+			t.Errorf("want token %q to be used up; got %v", token, err)
+		}
+	}
+
+	if err = s.Resets.Insert(ctx, "bob@example.com", "inactive", expires); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Users.Deactivate(ctx, bob); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Resets.Check(ctx, "inactive"); !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Errorf("want token of an inactive user to be rejected; got %v", err)
+	}
+	if err = s.Resets.Insert(ctx, "bob@example.com", "other", expires); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v for an inactive user; got %v", models.ErrNoRecord, err)
+	}
+}
+
+func testVerifications(t *testing.T, s *Stores) {
+	alice := insertUser(t, s, "Alice", "alice@example.com")
+	bob := insertUser(t, s, "Bob", "bob@example.com")
+	expires := now().Add(time.Hour)
+
+	// verified reports whether the address of the user is verified.
+	verified := func(id int) bool {
+		t.Helper()
+
+		user, err := s.Users.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user.Verified
+	}
+
+	if verified(alice) {
+		t.Errorf("want new user to be unverified")
+	}
+
+	sent, err := s.Verifs.Sent(ctx, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sent.IsZero() {
+		t.Errorf("want no token to be sent yet; got %v", sent)
+	}
+
+	if err = s.Verifs.Insert(ctx, "nobody@example.com", "unknown", expires); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v for an unknown email; got %v", models.ErrNoRecord, err)
+	}
+
+	for _, token := range []string{"first", "second"} {
+		if err = s.Verifs.Insert(ctx, "alice@example.com", token, expires); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = s.Verifs.Insert(ctx, "bob@example.com", "expired", now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	sent, err = s.Verifs.Sent(ctx, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent.Before(now().Add(-time.Minute)) {
+		t.Errorf("want token to be sent just now; got %v", sent)
+	}
+
+	for _, token := range []string{"first", "expired", "wrong"} {
+		if _, err = s.Verifs.Verify(ctx, token); !errors.Is(err, models.ErrInvalidCredentials) {
+			t.Errorf("want %v for token %q; got %v", models.ErrInvalidCredentials, token, err)
+		}
+	}
+
+	id, err := s.Verifs.Verify(ctx, "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != alice {
+		t.Errorf("want user %d; got %d", alice, id)
+	}
+	if !verified(alice) {
+		t.Errorf("want address to be verified")
+	}
+	if verified(bob) {
+		t.Errorf("want address of others to stay unverified")
+	}
+
+	if _, err = s.Verifs.Verify(ctx, "second"); !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Errorf("want token to be used up; got %v", err)
+	}
+
+	// a new token makes the address pending again
+	if err = s.Verifs.Insert(ctx, "alice@example.com", "third", expires); err != nil {
+		t.Fatal(err)
+	}
+	if verified(alice) {
+		t.Errorf("want address to be unverified until the new token comes back")
+	}
+
+	if err = s.Users.Deactivate(ctx, bob); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Verifs.Insert(ctx, "bob@example.com", "inactive", expires); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v for an inactive user; got %v", models.ErrNoRecord, err)
+	}
+}
+
+func testAudit(t *testing.T, s *Stores) {
+	admin := insertUser(t, s, "Alice", "alice@example.com")
+	bob := insertUser(t, s, "Bob", "bob@example.com")
+
+	actions := []models.Action{models.ActionPromoteUser, models.ActionDeactivateUser, models.ActionHideEvent}
+	for _, action := range actions {
+		if err := s.Audit.Insert(ctx, admin, action, bob); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := []models.Action{}
+	before := 0
+	for pages := 0; ; pages++ {
+		if pages > len(actions) {
+			t.Fatalf("want at most %d pages; got more", len(actions))
+		}
+
+		page, err := s.Audit.List(ctx, before, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, e := range page.Entries {
+			got = append(got, e.Action)
+			if e.AdminID != admin || e.AdminName != "Alice" || e.TargetID != bob || e.Created.IsZero() {
+				t.Errorf("want entry by %d on %d; got %+v", admin, bob, e)
+			}
+		}
+
+		if page.Next == 0 {
+			break
+		}
+		before = page.Next
+	}
+
+	want := []models.Action{models.ActionHideEvent, models.ActionDeactivateUser, models.ActionPromoteUser}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want actions %v; got %v", want, got)
+	}
+
+	// the trail outlives the administrators
+	if err := s.Users.Erase(ctx, admin); err != nil {
+		t.Fatal(err)
+	}
+
+	// actions from the command line have no administrator
+	if err := s.Audit.Insert(ctx, 0, models.ActionPromoteUser, bob); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := s.Audit.List(ctx, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Entries) != len(actions)+1 {
+		t.Fatalf("want %d entries; got %d", len(actions)+1, len(page.Entries))
+	}
+	for _, e := range page.Entries {
+		if e.AdminID != 0 || e.AdminName != "" {
+			t.Errorf("want entry without administrator; got %+v", e)
+		}
+	}
+}
+
+func ids(events []*models.Event) []int {
+	ids := []int{}
+	for _, evt := range events {
+		ids = append(ids, evt.ID)
+	}
+	return ids
+}
+
+// sameIDs reports whether events hold exactly the given IDs, in any order.
+func sameIDs(events []*models.Event, want []int) bool {
+	if len(events) != len(want) {
+		return false
+	}
+
+	seen := map[int]bool{}
+	for _, evt := range events {
+		seen[evt.ID] = true
+	}
+
+	for _, id := range want {
+		if !seen[id] {
+			return false
+		}
+	}
+
+	return true
+}
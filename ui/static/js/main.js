@@ -5,4 +5,30 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
-}
\ No newline at end of file
+}
+
+var addSlot = document.getElementById("add-slot");
+if (addSlot) {
+	addSlot.addEventListener("click", function() {
+		var slots = document.getElementById("slots");
+		var row = slots.lastElementChild.cloneNode(true);
+		var inputs = row.querySelectorAll("input");
+		for (var i = 0; i < inputs.length; i++) {
+			inputs[i].value = "";
+		}
+		slots.appendChild(row);
+	});
+}
+
+// Detect the time zone of the browser, so that the server can render times
+// in the zone of anonymous visitors. The page is reloaded once to apply it,
+// unless it answers a form that would be submitted again.
+if (document.cookie.indexOf("tz=") === -1 && window.Intl) {
+	var tz = Intl.DateTimeFormat().resolvedOptions().timeZone;
+	if (tz) {
+		document.cookie = "tz=" + encodeURIComponent(tz) + "; path=/; max-age=31536000; samesite=lax";
+		if (document.cookie.indexOf("tz=") !== -1 && tz !== "UTC" && document.body.hasAttribute("data-reloadable")) {
+			window.location.reload();
+		}
+	}
+}